@@ -14,7 +14,11 @@ import {
 	parseHistoryPayload,
 	parseRequestPayload,
 	parseQueryPayload,
-	parseErrorPayload
+	parseErrorPayload,
+	parseEvictionPayload,
+	parseAnnotationPayload,
+	parseSearchPayload,
+	parseCommandErrorPayload
 } from './websocket.js';
 
 /**
@@ -33,6 +37,7 @@ export function createDebugBarStore(config: DebugBarConfig) {
 	});
 
 	let wsClient: DebugBarWebSocket | null = null;
+	let searchSeq = 0;
 
 	// Initialize WebSocket connection
 	function init(): void {
@@ -145,6 +150,59 @@ export function createDebugBarStore(config: DebugBarConfig) {
 					}
 					break;
 				}
+
+				case 'evicted': {
+					const eviction = parseEvictionPayload(message.payload);
+					if (eviction) {
+						// Keep evicted requests visible but mark them as gone from the server
+						const ids = new Set(eviction.ids);
+						const mark = (r: RequestInfo) => (ids.has(r.id) ? { ...r, evicted: true } : r);
+						state.update((s) => ({
+							...s,
+							requests: s.requests.map(mark),
+							selectedRequest: s.selectedRequest ? mark(s.selectedRequest) : null
+						}));
+					}
+					break;
+				}
+
+				case 'annotation': {
+					const annotation = parseAnnotationPayload(message.payload);
+					if (annotation) {
+						const apply = (r: RequestInfo) =>
+							r.id === annotation.request_id
+								? {
+										...r,
+										pinned: annotation.pinned,
+										notes: annotation.notes,
+										labels: annotation.labels
+									}
+								: r;
+						state.update((s) => ({
+							...s,
+							requests: s.requests.map(apply),
+							selectedRequest: s.selectedRequest ? apply(s.selectedRequest) : null
+						}));
+					}
+					break;
+				}
+
+				case 'search_results': {
+					const result = parseSearchPayload(message.payload);
+					// Ignore results of searches superseded by a newer one
+					if (result && result.id === String(searchSeq)) {
+						state.update((s) => ({ ...s, searchResult: result, searchError: undefined }));
+					}
+					break;
+				}
+
+				case 'command_error': {
+					const error = parseCommandErrorPayload(message.payload);
+					if (error?.command === 'search' && error.id === String(searchSeq)) {
+						state.update((s) => ({ ...s, searchResult: null, searchError: error.error }));
+					}
+					break;
+				}
 			}
 		});
 	}
@@ -178,6 +236,32 @@ export function createDebugBarStore(config: DebugBarConfig) {
 		state.update((s) => ({ ...s, requests: [], selectedRequest: null }));
 	}
 
+	function search(query: string, offset = 0, limit = 50): void {
+		searchSeq++;
+		if (!query.trim()) {
+			state.update((s) => ({ ...s, searchResult: null, searchError: undefined }));
+			return;
+		}
+		wsClient?.search(String(searchSeq), query, offset, limit);
+	}
+
+	// Annotation changes come back to every client as annotation messages
+	function pinRequest(requestId: string, pinned: boolean): void {
+		wsClient?.send({ type: 'pin', payload: { request_id: requestId, pinned } });
+	}
+
+	function addNote(requestId: string, text: string, author?: string): void {
+		wsClient?.send({ type: 'add_note', payload: { request_id: requestId, text, author } });
+	}
+
+	function removeNote(requestId: string, noteId: string): void {
+		wsClient?.send({ type: 'remove_note', payload: { request_id: requestId, note_id: noteId } });
+	}
+
+	function setLabels(requestId: string, labels: string[]): void {
+		wsClient?.send({ type: 'set_labels', payload: { request_id: requestId, labels } });
+	}
+
 	function destroy(): void {
 		wsClient?.destroy();
 		wsClient = null;
@@ -229,6 +313,11 @@ export function createDebugBarStore(config: DebugBarConfig) {
 		toggleMinimized,
 		setMinimized,
 		clearRequests,
+		search,
+		pinRequest,
+		addNote,
+		removeNote,
+		setLabels,
 		destroy
 	};
 }
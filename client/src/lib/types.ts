@@ -5,20 +5,174 @@ export interface RequestInfo {
 	id: string;
 	method: string;
 	path: string;
+	/** Route pattern such as /users/:id, empty for unmatched requests */
+	route?: string;
+	path_params?: PathParam[];
+	handler_name?: string;
+	/** Set when no route matched the request (404 / 405) */
+	unmatched?: boolean;
 	status_code: number;
 	duration: number;
 	duration_ms: number;
 	start_time: string;
 	end_time: string;
-	headers: Record<string, string>;
-	query_params: Record<string, string>;
+	/** First value of each request header (omitted when the server uses MultiValueOnly) */
+	headers?: Record<string, string>;
+	/** First value of each query parameter (omitted when the server uses MultiValueOnly) */
+	query_params?: Record<string, string>;
+	request_headers: Field[];
+	query_values: Field[];
+	response_headers?: Field[];
 	request_body?: string;
+	request_body_size: number;
+	request_body_truncated?: boolean;
+	request_body_format?: BodyFormat;
+	form?: FormData;
 	response_size: number;
+	response_content_type?: string;
+	response_body?: string;
+	response_body_truncated?: boolean;
+	response_body_format?: BodyFormat;
 	client_ip: string;
+	user?: UserInfo;
+	activated_by?: string;
 	queries: QueryInfo[];
 	errors: ErrorInfo[];
 	memory_usage: number;
 	custom_data?: Record<string, unknown>;
+	context_keys?: Record<string, unknown>;
+	validation_errors?: ValidationError[];
+	request_cookies?: CookieInfo[];
+	response_cookies?: CookieInfo[];
+	session?: SessionInfo;
+	handlers?: HandlerTiming[];
+	aborted_by?: string;
+	/** Recorded after the fact by an always-capture setting, without queries, bodies or timings */
+	partial?: boolean;
+	pinned?: boolean;
+	notes?: RequestNote[];
+	labels?: string[];
+	/** Loaded from an archive, can't be changed */
+	imported?: boolean;
+	/** When the request was imported, retention ages it from then */
+	imported_at?: string;
+	/** Set by the client when the server has evicted the request */
+	evicted?: boolean;
+}
+
+/**
+ * A failed validation rule from request binding
+ */
+export interface ValidationError {
+	field: string;
+	namespace: string;
+	tag: string;
+	param?: string;
+	value: unknown;
+	message: string;
+}
+
+/**
+ * A request cookie, or a cookie set by the response with its attributes
+ */
+export interface CookieInfo {
+	name: string;
+	value: string;
+	path?: string;
+	domain?: string;
+	expires?: string;
+	max_age?: number;
+	secure?: boolean;
+	http_only?: boolean;
+	same_site?: 'Lax' | 'Strict' | 'None';
+}
+
+/**
+ * Session values at the start and end of a request
+ */
+export interface SessionInfo {
+	before: Record<string, unknown> | null;
+	after: Record<string, unknown> | null;
+	changes?: SessionChange[];
+	error?: string;
+}
+
+/**
+ * A session key added, changed or removed by a request
+ */
+export interface SessionChange {
+	key: string;
+	type: 'added' | 'removed' | 'changed';
+	before?: unknown;
+	after?: unknown;
+}
+
+/**
+ * Timing of a handler in the Gin chain
+ */
+export interface HandlerTiming {
+	name: string;
+	depth: number;
+	offset: number;
+	offset_ms: number;
+	duration: number;
+	duration_ms: number;
+	self_duration: number;
+	self_duration_ms: number;
+	aborted?: boolean;
+}
+
+/**
+ * How a captured body is represented: plain text, the output of a registered
+ * decoder, or a base64 / hex dump preview of binary data
+ */
+export type BodyFormat = 'text' | 'decoded' | 'base64' | 'hex';
+
+/**
+ * The authenticated user that made a request
+ */
+export interface UserInfo {
+	id: string;
+	name?: string;
+	roles?: string[];
+	attributes?: Record<string, unknown>;
+}
+
+/**
+ * A parameter matched from the route pattern
+ */
+export interface PathParam {
+	key: string;
+	value: string;
+}
+
+/**
+ * A named list of values, preserving repeated headers and query parameters
+ */
+export interface Field {
+	name: string;
+	values: string[];
+}
+
+/**
+ * Parsed urlencoded or multipart request body
+ */
+export interface FormData {
+	fields: Field[];
+	files?: FileInfo[];
+	truncated?: boolean;
+}
+
+/**
+ * Metadata about an uploaded file (contents are not captured)
+ */
+export interface FileInfo {
+	field: string;
+	filename: string;
+	content_type?: string;
+	detected_type?: string;
+	size: number;
+	size_truncated?: boolean;
 }
 
 /**
@@ -63,6 +217,54 @@ export interface WebSocketMessage {
 	payload: unknown;
 }
 
+/**
+ * A free-text note attached to a request
+ */
+export interface RequestNote {
+	id: string;
+	text: string;
+	author?: string;
+	created_at: string;
+}
+
+/**
+ * The pin, notes and labels of a request, sent whenever they change
+ */
+export interface Annotation {
+	request_id: string;
+	pinned: boolean;
+	notes: RequestNote[];
+	labels: string[];
+}
+
+/**
+ * Requests removed from the server's history
+ */
+export interface EvictionInfo {
+	ids: string[];
+	reason: 'count' | 'bytes' | 'age' | 'delete' | 'clear';
+}
+
+/**
+ * A page of request IDs matching a search, newest first
+ */
+export interface SearchResult {
+	id?: string;
+	ids: string[];
+	total: number;
+	offset: number;
+	limit: number;
+}
+
+/**
+ * A command the server could not run
+ */
+export interface CommandError {
+	id?: string;
+	command: string;
+	error: string;
+}
+
 /**
  * Message types for WebSocket communication
  */
@@ -72,6 +274,15 @@ export type MessageType =
 	| 'error'
 	| 'request_end'
 	| 'history'
+	| 'evicted'
+	| 'annotation'
+	| 'search'
+	| 'search_results'
+	| 'pin'
+	| 'add_note'
+	| 'remove_note'
+	| 'set_labels'
+	| 'command_error'
 	| 'ping'
 	| 'pong';
 
@@ -148,6 +359,12 @@ export interface DebugBarState {
 
 	/** Error message if connection failed */
 	errorMessage?: string;
+
+	/** Results of the last search, null when not searching */
+	searchResult?: SearchResult | null;
+
+	/** Error from the last search */
+	searchError?: string;
 }
 
 /**
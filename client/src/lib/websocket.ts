@@ -4,6 +4,10 @@ import type {
 	RequestInfo,
 	QueryInfo,
 	ErrorInfo,
+	EvictionInfo,
+	Annotation,
+	SearchResult,
+	CommandError,
 	ConnectionStatus
 } from './types.js';
 import { defaultConfig } from './types.js';
@@ -100,6 +104,14 @@ export class DebugBarWebSocket {
 		}
 	}
 
+	/**
+	 * Search the server's history, e.g. `method:POST status:>=500`. The
+	 * result arrives as a search_results message with the same id.
+	 */
+	search(id: string, query: string, offset = 0, limit = 50): void {
+		this.send({ type: 'search', payload: { id, query, offset, limit } });
+	}
+
 	/**
 	 * Send a ping message
 	 */
@@ -244,6 +256,46 @@ export function parseQueryPayload(payload: unknown): QueryInfo | null {
 	return payload as QueryInfo;
 }
 
+/**
+ * Parse an eviction message payload
+ */
+export function parseEvictionPayload(payload: unknown): EvictionInfo | null {
+	if (!payload || typeof payload !== 'object' || !Array.isArray((payload as EvictionInfo).ids)) {
+		return null;
+	}
+	return payload as EvictionInfo;
+}
+
+/**
+ * Parse an annotation message payload
+ */
+export function parseAnnotationPayload(payload: unknown): Annotation | null {
+	if (!payload || typeof payload !== 'object' || !(payload as Annotation).request_id) {
+		return null;
+	}
+	return payload as Annotation;
+}
+
+/**
+ * Parse a search results payload
+ */
+export function parseSearchPayload(payload: unknown): SearchResult | null {
+	if (!payload || typeof payload !== 'object' || !Array.isArray((payload as SearchResult).ids)) {
+		return null;
+	}
+	return payload as SearchResult;
+}
+
+/**
+ * Parse a command error payload
+ */
+export function parseCommandErrorPayload(payload: unknown): CommandError | null {
+	if (!payload || typeof payload !== 'object' || typeof (payload as CommandError).error !== 'string') {
+		return null;
+	}
+	return payload as CommandError;
+}
+
 /**
  * Parse an error message payload
  */
@@ -0,0 +1,170 @@
+package godebugbar
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default names used to carry an activation token
+const (
+	DefaultActivationCookie = "debugbar_token"
+	DefaultActivationHeader = "X-Debugbar-Token"
+	DefaultActivationQuery  = "_debugbar"
+)
+
+// Errors returned when verifying activation tokens
+var (
+	ErrInvalidActivationToken = errors.New("invalid activation token")
+	ErrExpiredActivationToken = errors.New("activation token has expired")
+)
+
+// ActivationConfig limits capture to requests that carry a signed
+// activation token, so the debug bar can stay enabled in shared
+// environments without recording everyone's traffic
+type ActivationConfig struct {
+	// Secret is the HMAC key used to sign and verify tokens
+	Secret []byte
+
+	// CookieName is the cookie checked for a token. Defaults to
+	// DefaultActivationCookie.
+	CookieName string
+
+	// HeaderName is the request header checked for a token. Defaults to
+	// DefaultActivationHeader.
+	HeaderName string
+
+	// QueryParam is the query parameter checked for a token. A valid token
+	// passed this way is also set as the cookie, so one link activates a
+	// browser session. Defaults to DefaultActivationQuery.
+	QueryParam string
+}
+
+// withDefaults fills in the default token names
+func (a ActivationConfig) withDefaults() ActivationConfig {
+	if a.CookieName == "" {
+		a.CookieName = DefaultActivationCookie
+	}
+	if a.HeaderName == "" {
+		a.HeaderName = DefaultActivationHeader
+	}
+	if a.QueryParam == "" {
+		a.QueryParam = DefaultActivationQuery
+	}
+	return a
+}
+
+// SignActivationToken creates a token for subject, such as a developer's
+// name, that is valid until expires. Tokens have the form
+// "expiry.subject.signature" with the subject base64url encoded.
+func SignActivationToken(secret []byte, subject string, expires time.Time) string {
+	payload := strconv.FormatInt(expires.Unix(), 10) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(subject))
+	return payload + "." + activationSignature(secret, payload)
+}
+
+// VerifyActivationToken checks a token's signature and expiry and returns
+// the subject it was issued to
+func VerifyActivationToken(secret []byte, token string, now time.Time) (string, error) {
+	payload, signature, ok := cutLast(token, ".")
+	if !ok || len(secret) == 0 {
+		return "", ErrInvalidActivationToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(activationSignature(secret, payload))) {
+		return "", ErrInvalidActivationToken
+	}
+
+	expiry, encodedSubject, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", ErrInvalidActivationToken
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", ErrInvalidActivationToken
+	}
+	subject, err := base64.RawURLEncoding.DecodeString(encodedSubject)
+	if err != nil {
+		return "", ErrInvalidActivationToken
+	}
+	if !now.Before(time.Unix(unix, 0)) {
+		return "", ErrExpiredActivationToken
+	}
+	return string(subject), nil
+}
+
+// ActivationToken creates a token for subject valid for ttl, signed with
+// the configured activation secret
+func (d *DebugBar) ActivationToken(subject string, ttl time.Duration) (string, error) {
+	if d.config.Activation == nil || len(d.config.Activation.Secret) == 0 {
+		return "", errors.New("activation is not configured")
+	}
+	return SignActivationToken(d.config.Activation.Secret, subject, d.clock().Add(ttl)), nil
+}
+
+// activate reports whether a request should be captured and returns the
+// subject of its activation token. Every request is captured when
+// activation is not configured.
+func (d *DebugBar) activate(c *gin.Context) (string, bool) {
+	if d.config.Activation == nil {
+		return "", true
+	}
+	activation := d.config.Activation.withDefaults()
+
+	if token := c.GetHeader(activation.HeaderName); token != "" {
+		subject, err := VerifyActivationToken(activation.Secret, token, d.clock())
+		return subject, err == nil
+	}
+
+	if token := c.Query(activation.QueryParam); token != "" {
+		subject, err := VerifyActivationToken(activation.Secret, token, d.clock())
+		if err != nil {
+			return "", false
+		}
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     activation.CookieName,
+			Value:    token,
+			Path:     "/",
+			Expires:  activationExpiry(token),
+			HttpOnly: true,
+			Secure:   c.Request.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return subject, true
+	}
+
+	if cookie, err := c.Cookie(activation.CookieName); err == nil && cookie != "" {
+		subject, err := VerifyActivationToken(activation.Secret, cookie, d.clock())
+		return subject, err == nil
+	}
+
+	return "", false
+}
+
+// activationSignature returns the base64url HMAC-SHA256 of payload
+func activationSignature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// activationExpiry returns the expiry of an already verified token
+func activationExpiry(token string) time.Time {
+	expiry, _, _ := strings.Cut(token, ".")
+	unix, _ := strconv.ParseInt(expiry, 10, 64)
+	return time.Unix(unix, 0)
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,124 @@
+package godebugbar
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifyActivationToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	valid := SignActivationToken(secret, "ada@example.com", now.Add(time.Hour))
+	payload, _, _ := cutLast(valid, ".")
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		token   string
+		subject string
+		err     error
+	}{
+		{"valid", secret, valid, "ada@example.com", nil},
+		{"subject with dots", secret, SignActivationToken(secret, "a.b.c", now.Add(time.Minute)), "a.b.c", nil},
+		{"expired", secret, SignActivationToken(secret, "ada", now), "", ErrExpiredActivationToken},
+		{"wrong secret", []byte("other"), valid, "", ErrInvalidActivationToken},
+		{"no secret", nil, valid, "", ErrInvalidActivationToken},
+		{"tampered subject", secret, strings.Replace(valid, payload, payload+"x", 1), "", ErrInvalidActivationToken},
+		{"no signature", secret, payload, "", ErrInvalidActivationToken},
+		{"empty", secret, "", "", ErrInvalidActivationToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, err := VerifyActivationToken(tt.secret, tt.token, now)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("VerifyActivationToken() error = %v, want %v", err, tt.err)
+			}
+			if subject != tt.subject {
+				t.Errorf("VerifyActivationToken() subject = %q, want %q", subject, tt.subject)
+			}
+		})
+	}
+}
+
+func TestActivationMiddleware(t *testing.T) {
+	config := testConfig()
+	config.Activation = &ActivationConfig{Secret: []byte("secret")}
+	d := New(config)
+	engine := testEngine(t, d)
+	engine.GET("/", func(c *gin.Context) {})
+
+	token, err := d.ActivationToken("ada", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := SignActivationToken([]byte("secret"), "ada", time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name    string
+		path    string
+		header  string
+		cookie  string
+		capture bool
+	}{
+		{"no token", "/", "", "", false},
+		{"header", "/", token, "", true},
+		{"query", "/?" + DefaultActivationQuery + "=" + token, "", "", true},
+		{"cookie", "/", "", token, true},
+		{"expired header", "/", expired, "", false},
+		{"invalid header beats a valid cookie", "/", "bogus", token, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(DefaultActivationHeader, tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: DefaultActivationCookie, Value: tt.cookie})
+			}
+
+			w, captured := serve(t, d, engine, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("status %d, want the request served either way", w.Code)
+			}
+			if (captured != nil) != tt.capture {
+				t.Fatalf("captured = %v, want %v", captured != nil, tt.capture)
+			}
+			if captured == nil {
+				return
+			}
+			if captured.ActivatedBy != "ada" {
+				t.Errorf("ActivatedBy = %q, want the token's subject", captured.ActivatedBy)
+			}
+			// The token itself never shows up in the capture
+			if v := captured.RequestHeaders.Get(DefaultActivationHeader); v != "" && v != DefaultRedactionReplacement {
+				t.Errorf("activation header captured as %q", v)
+			}
+			if v := captured.QueryValues.Get(DefaultActivationQuery); v != "" && v != DefaultRedactionReplacement {
+				t.Errorf("activation query parameter captured as %q", v)
+			}
+		})
+	}
+
+	// A query token activates the browser with a cookie
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?"+DefaultActivationQuery+"="+token, nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DefaultActivationCookie || cookies[0].Value != token || !cookies[0].HttpOnly {
+		t.Errorf("cookies = %+v, want the activation cookie", cookies)
+	}
+}
+
+func TestActivationTokenUnconfigured(t *testing.T) {
+	if _, err := New(testConfig()).ActivationToken("ada", time.Hour); err == nil {
+		t.Error("ActivationToken() succeeded without a secret")
+	}
+}
@@ -0,0 +1,156 @@
+package godebugbar
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrPinLimit is returned when pinning a request would leave no room in
+// the history for new requests
+var ErrPinLimit = errors.New("too many pinned requests, unpin some first")
+
+// ErrNoteNotFound is returned when removing a note a request doesn't have
+var ErrNoteNotFound = errors.New("note not found")
+
+// RequestNote is a free-text note attached to a captured request
+type RequestNote struct {
+	ID        string    `json:"id"`
+	Text      string    `json:"text"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Annotation is the pin, notes and labels of a request. It is broadcast to
+// every client whenever one of them changes.
+type Annotation struct {
+	RequestID string        `json:"request_id"`
+	Pinned    bool          `json:"pinned"`
+	Notes     []RequestNote `json:"notes"`
+	Labels    []string      `json:"labels"`
+}
+
+// PinRequest pins or unpins a stored request. Pinned requests are never
+// evicted by retention.
+func (d *DebugBar) PinRequest(id string, pinned bool) error {
+	return d.annotate(id, func(req *RequestInfo) error {
+		if pinned && !req.Pinned && d.config.MaxRequests > 0 && d.retention.pinned() >= d.config.MaxRequests-1 {
+			return ErrPinLimit
+		}
+		req.Pinned = pinned
+		return nil
+	})
+}
+
+// AddNote attaches a note to a stored request. The author is free text,
+// such as a name the client chose.
+func (d *DebugBar) AddNote(id, text, author string) (RequestNote, error) {
+	note := RequestNote{
+		ID:        uuid.New().String(),
+		Text:      strings.TrimSpace(text),
+		Author:    strings.TrimSpace(author),
+		CreatedAt: d.clock(),
+	}
+	if note.Text == "" {
+		return RequestNote{}, errors.New("note text is empty")
+	}
+
+	err := d.annotate(id, func(req *RequestInfo) error {
+		req.Notes = append(req.Notes, note)
+		return nil
+	})
+	if err != nil {
+		return RequestNote{}, err
+	}
+	return note, nil
+}
+
+// RemoveNote removes a note from a stored request
+func (d *DebugBar) RemoveNote(id, noteID string) error {
+	return d.annotate(id, func(req *RequestInfo) error {
+		i := slices.IndexFunc(req.Notes, func(note RequestNote) bool { return note.ID == noteID })
+		if i < 0 {
+			return ErrNoteNotFound
+		}
+		req.Notes = slices.Delete(req.Notes, i, i+1)
+		return nil
+	})
+}
+
+// SetLabels replaces the labels of a stored request. Labels are trimmed
+// and duplicates dropped.
+func (d *DebugBar) SetLabels(id string, labels []string) error {
+	cleaned := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label != "" && !slices.Contains(cleaned, label) {
+			cleaned = append(cleaned, label)
+		}
+	}
+
+	return d.annotate(id, func(req *RequestInfo) error {
+		req.Labels = cleaned
+		return nil
+	})
+}
+
+// annotate applies change to a copy of a stored request, saves it and
+// broadcasts the new annotation. Stored requests may be read concurrently,
+// so they are never modified in place. Imported requests can't be changed.
+func (d *DebugBar) annotate(id string, change func(req *RequestInfo) error) error {
+	if d.config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	d.storeMu.Lock()
+	stored, err := d.store.Get(id)
+	if err != nil {
+		d.storeMu.Unlock()
+		return err
+	}
+	if stored.Imported {
+		d.storeMu.Unlock()
+		return ErrReadOnly
+	}
+
+	req := *stored
+	req.Notes = slices.Clone(stored.Notes)
+	req.Labels = slices.Clone(stored.Labels)
+	if err := change(&req); err != nil {
+		d.storeMu.Unlock()
+		return err
+	}
+
+	if err := d.store.Update(&req); err != nil {
+		d.storeMu.Unlock()
+		return err
+	}
+	d.retention.retier(&req, d.retention.sizeOf(&req))
+	d.storeMu.Unlock()
+
+	d.broadcast(WebSocketMessage{
+		Type:    MessageTypeAnnotation,
+		Payload: annotationOf(&req),
+	})
+	return nil
+}
+
+// annotationOf returns the annotation of a request
+func annotationOf(req *RequestInfo) Annotation {
+	annotation := Annotation{
+		RequestID: req.ID,
+		Pinned:    req.Pinned,
+		Notes:     req.Notes,
+		Labels:    req.Labels,
+	}
+	if annotation.Notes == nil {
+		annotation.Notes = make([]RequestNote, 0)
+	}
+	if annotation.Labels == nil {
+		annotation.Labels = make([]string, 0)
+	}
+	return annotation
+}
@@ -0,0 +1,125 @@
+package godebugbar
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// annotateTestBar returns a debug bar holding requests r0 to r(n-1)
+func annotateTestBar(t *testing.T, config Config, n int) *DebugBar {
+	t.Helper()
+	d := New(config)
+	t.Cleanup(func() { d.Close() })
+	for i := range n {
+		d.storeRequest(&RequestInfo{ID: fmt.Sprint("r", i), Method: "GET", Path: "/", StartTime: time.Now()})
+	}
+	return d
+}
+
+func TestPinRequest(t *testing.T) {
+	config := testConfig()
+	config.MaxRequests = 3
+	d := annotateTestBar(t, config, 3)
+
+	if err := d.PinRequest("r0", true); err != nil {
+		t.Fatalf("PinRequest() error = %v", err)
+	}
+	if err := d.PinRequest("r1", true); err != nil {
+		t.Fatalf("PinRequest() error = %v", err)
+	}
+	// Pinning everything would leave no room for new requests
+	if err := d.PinRequest("r2", true); !errors.Is(err, ErrPinLimit) {
+		t.Errorf("third PinRequest() error = %v, want ErrPinLimit", err)
+	}
+	// Pinning a pinned request again doesn't count twice
+	if err := d.PinRequest("r1", true); err != nil {
+		t.Errorf("repeated PinRequest() error = %v", err)
+	}
+	if err := d.PinRequest("missing", true); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("PinRequest(missing) error = %v, want ErrRequestNotFound", err)
+	}
+
+	// New requests evict the unpinned one only
+	for i := 3; i < 6; i++ {
+		d.storeRequest(&RequestInfo{ID: fmt.Sprint("r", i), StartTime: time.Now()})
+	}
+	history := d.GetHistory()
+	if ids := requestIDs(history); !reflect.DeepEqual(ids, []string{"r0", "r1", "r5"}) {
+		t.Errorf("history = %v, want the pinned requests kept", ids)
+	}
+
+	if err := d.PinRequest("r0", false); err != nil {
+		t.Fatalf("unpinning error = %v", err)
+	}
+	d.storeRequest(&RequestInfo{ID: "r6", StartTime: time.Now()})
+	if ids := requestIDs(d.GetHistory()); !reflect.DeepEqual(ids, []string{"r1", "r5", "r6"}) {
+		t.Errorf("history = %v, want the unpinned request evicted", ids)
+	}
+}
+
+func TestNotesAndLabels(t *testing.T) {
+	d := annotateTestBar(t, testConfig(), 1)
+
+	// Readers holding the stored request don't see it change
+	before, err := d.store.Get("r0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := d.AddNote("r0", "  slow on staging ", " ada ")
+	if err != nil {
+		t.Fatalf("AddNote() error = %v", err)
+	}
+	if note.Text != "slow on staging" || note.Author != "ada" || note.ID == "" {
+		t.Errorf("AddNote() = %+v, want a trimmed note", note)
+	}
+	if _, err := d.AddNote("r0", "   ", "ada"); err == nil {
+		t.Error("AddNote() accepted an empty note")
+	}
+	if err := d.SetLabels("r0", []string{" bug ", "", "bug", "perf"}); err != nil {
+		t.Fatalf("SetLabels() error = %v", err)
+	}
+
+	after, _ := d.store.Get("r0")
+	if len(after.Notes) != 1 || after.Notes[0] != note || !reflect.DeepEqual(after.Labels, []string{"bug", "perf"}) {
+		t.Errorf("stored request has notes %+v and labels %q", after.Notes, after.Labels)
+	}
+	if before.Notes != nil || before.Labels != nil {
+		t.Errorf("the request read earlier was changed in place: %+v %q", before.Notes, before.Labels)
+	}
+
+	if err := d.RemoveNote("r0", "missing"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("RemoveNote(missing) error = %v, want ErrNoteNotFound", err)
+	}
+	if err := d.RemoveNote("r0", note.ID); err != nil {
+		t.Fatalf("RemoveNote() error = %v", err)
+	}
+	if req, _ := d.store.Get("r0"); len(req.Notes) != 0 || len(after.Notes) != 1 {
+		t.Errorf("notes = %+v, earlier copy %+v, want only the new copy changed", req.Notes, after.Notes)
+	}
+
+	if got := annotationOf(&RequestInfo{ID: "x"}); got.Notes == nil || got.Labels == nil {
+		t.Errorf("annotationOf() = %+v, want empty lists for clients", got)
+	}
+}
+
+func TestAnnotateReadOnly(t *testing.T) {
+	config := testConfig()
+	config.ReadOnly = true
+	d := annotateTestBar(t, config, 1)
+	if err := d.PinRequest("r0", true); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("PinRequest() error = %v, want ErrReadOnly", err)
+	}
+	if _, err := d.AddNote("r0", "note", ""); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AddNote() error = %v, want ErrReadOnly", err)
+	}
+
+	d = annotateTestBar(t, testConfig(), 0)
+	d.storeRequest(&RequestInfo{ID: "imported", Imported: true, StartTime: time.Now()})
+	if err := d.SetLabels("imported", []string{"bug"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("SetLabels() on an imported request error = %v, want ErrReadOnly", err)
+	}
+}
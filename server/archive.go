@@ -0,0 +1,207 @@
+package godebugbar
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ArchiveFormat identifies debug bar archives in their header
+const ArchiveFormat = "godebugbar-archive"
+
+// ArchiveVersion is the archive format version written by Export. Import
+// reads this version and older ones.
+const ArchiveVersion = 1
+
+// ErrReadOnly is returned when changing the history of a read-only debug
+// bar or an imported request
+var ErrReadOnly = errors.New("debug bar history is read-only")
+
+// ErrInvalidArchive is returned by Import for data that isn't a debug bar
+// archive or uses a newer format version
+var ErrInvalidArchive = errors.New("invalid debug bar archive")
+
+// ErrArchiveTooLarge is returned by Import for archives that decompress to
+// more than MaxArchiveSize bytes or hold more requests than the history
+// can keep
+var ErrArchiveTooLarge = errors.New("debug bar archive is too large")
+
+// MaxArchiveSize limits the decompressed size of an archive read by Import
+const MaxArchiveSize = 256 << 20
+
+// ArchiveHeader is the first line of an archive
+type ArchiveHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Query      string    `json:"query,omitempty"`
+	Count      int       `json:"count"`
+}
+
+// Export writes the stored requests matching query, or all requests when
+// query is empty, to w as a gzip-compressed NDJSON archive: a header line
+// followed by one request per line, oldest first. It returns the number of
+// requests written.
+func (d *DebugBar) Export(w io.Writer, query string) (int, error) {
+	q, err := ParseSearch(query)
+	if err != nil {
+		return 0, err
+	}
+	requests, err := d.store.List(StoreFilter{Match: q.Match, Search: q})
+	if err != nil {
+		return 0, err
+	}
+
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	header := ArchiveHeader{
+		Format:     ArchiveFormat,
+		Version:    ArchiveVersion,
+		ExportedAt: d.clock(),
+		Query:      query,
+		Count:      len(requests),
+	}
+	if err := encoder.Encode(header); err != nil {
+		return 0, err
+	}
+
+	for _, req := range requests {
+		if err := encoder.Encode(req); err != nil {
+			return 0, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	return len(requests), nil
+}
+
+// Import loads the requests of an archive written by Export into the
+// history, marked as imported so they can't be changed. Plain NDJSON is
+// accepted as well as gzip. The archive is read completely before
+// anything is stored, so an invalid archive imports nothing. Requests
+// already in the history are skipped, and imported requests are never
+// pinned. It returns the number of requests imported; retention may evict
+// some of them.
+func (d *DebugBar) Import(r io.Reader) (int, error) {
+	requests, err := readArchive(r, d.config.MaxRequests)
+	if err != nil {
+		return 0, err
+	}
+
+	now := d.clock()
+	imported := 0
+	for _, req := range requests {
+		if _, err := d.store.Get(req.ID); err == nil {
+			continue
+		} else if !errors.Is(err, ErrRequestNotFound) {
+			return imported, err
+		}
+
+		req.Imported = true
+		req.Pinned = false
+		// Age imported requests from now so retention doesn't drop old
+		// captures straight away, also after a restart
+		req.ImportedAt = &now
+		if err := d.saveRequest(req, d.retention.sizeOf(req), now); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+
+	if imported > 0 {
+		// Send the whole history so clients show the imported requests
+		d.broadcast(WebSocketMessage{
+			Type:    MessageTypeHistory,
+			Payload: d.GetHistory(),
+		})
+	}
+	return imported, nil
+}
+
+// archiveReader fails reads past MaxArchiveSize decompressed bytes
+type archiveReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// Read implements io.Reader
+func (a *archiveReader) Read(p []byte) (int, error) {
+	if a.remaining <= 0 {
+		// Only fail when there is more to read
+		var next [1]byte
+		if n, err := a.r.Read(next[:]); n == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, MaxArchiveSize)
+	}
+	if int64(len(p)) > a.remaining {
+		p = p[:a.remaining]
+	}
+	n, err := a.r.Read(p)
+	a.remaining -= int64(n)
+	return n, err
+}
+
+// readArchive decodes the header and requests of an archive, holding at
+// most maxRequests requests when it is greater than 0. Archive IDs must be
+// unique.
+func readArchive(r io.Reader, maxRequests int) ([]*RequestInfo, error) {
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = buffered
+	}
+
+	decoder := json.NewDecoder(&archiveReader{r: r, remaining: MaxArchiveSize})
+	var header ArchiveHeader
+	if err := decoder.Decode(&header); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %w", ErrInvalidArchive, err)
+	}
+	if header.Format != ArchiveFormat {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidArchive, header.Format)
+	}
+	if header.Version < 1 || header.Version > ArchiveVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, header.Version)
+	}
+
+	if maxRequests > 0 && header.Count > maxRequests {
+		return nil, fmt.Errorf("%w: %d requests, the history keeps %d", ErrArchiveTooLarge, header.Count, maxRequests)
+	}
+
+	var requests []*RequestInfo
+	seen := make(map[string]bool)
+	for line := 2; ; line++ {
+		req := &RequestInfo{}
+		err := decoder.Decode(req)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidArchive, line, err)
+		}
+		if req.ID == "" {
+			return nil, fmt.Errorf("%w: line %d: request has no id", ErrInvalidArchive, line)
+		}
+		if seen[req.ID] {
+			return nil, fmt.Errorf("%w: line %d: duplicate id %s", ErrInvalidArchive, line, req.ID)
+		}
+		if maxRequests > 0 && len(requests) == maxRequests {
+			return nil, fmt.Errorf("%w: the history keeps %d requests", ErrArchiveTooLarge, maxRequests)
+		}
+		seen[req.ID] = true
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
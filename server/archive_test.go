@@ -0,0 +1,152 @@
+package godebugbar
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testArchive exports requests stored in a new debug bar
+func testArchive(t *testing.T, query string, requests ...*RequestInfo) []byte {
+	t.Helper()
+	d := New(Config{Enabled: true, MaxRequests: 10})
+	for _, req := range requests {
+		d.storeRequest(req)
+	}
+	var buf bytes.Buffer
+	if _, err := d.Export(&buf, query); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExportFormat(t *testing.T) {
+	archive := testArchive(t, "status:5xx",
+		&RequestInfo{ID: "ok", StatusCode: 200},
+		&RequestInfo{ID: "first", StatusCode: 500},
+		&RequestInfo{ID: "second", StatusCode: 503},
+	)
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("archive isn't gzip: %v", err)
+	}
+	scanner := bufio.NewScanner(gz)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if len(lines) != 3 {
+		t.Fatalf("archive has %d lines, want a header and 2 requests", len(lines))
+	}
+	var header ArchiveHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Format != ArchiveFormat || header.Version != ArchiveVersion || header.Query != "status:5xx" || header.Count != 2 {
+		t.Errorf("header = %+v", header)
+	}
+	for i, id := range []string{"first", "second"} {
+		var req RequestInfo
+		if err := json.Unmarshal([]byte(lines[i+1]), &req); err != nil {
+			t.Fatal(err)
+		}
+		if req.ID != id {
+			t.Errorf("line %d has request %q, want %q", i+2, req.ID, id)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	archive := testArchive(t, "",
+		&RequestInfo{ID: "a", Pinned: true, StartTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		&RequestInfo{ID: "b"},
+	)
+
+	d := New(Config{Enabled: true, MaxRequests: 10})
+	d.storeRequest(&RequestInfo{ID: "b", Path: "/local"})
+	n, err := d.Import(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Import() = %d, want 1 as b is already stored", n)
+	}
+
+	imported, err := d.store.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !imported.Imported || imported.Pinned || imported.ImportedAt == nil {
+		t.Errorf("imported request = %+v, want imported, unpinned and with imported_at", imported)
+	}
+	if local, _ := d.store.Get("b"); local.Path != "/local" || local.Imported {
+		t.Errorf("local request was replaced: %+v", local)
+	}
+	if err := d.PinRequest("a", true); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("PinRequest(imported) error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	header := func(version, count int) string {
+		data, _ := json.Marshal(ArchiveHeader{Format: ArchiveFormat, Version: version, Count: count})
+		return string(data) + "\n"
+	}
+
+	tests := []struct {
+		name    string
+		archive string
+		want    error
+	}{
+		{"empty", "", ErrInvalidArchive},
+		{"not json", "hello\n", ErrInvalidArchive},
+		{"unknown format", `{"format":"other","version":1}` + "\n", ErrInvalidArchive},
+		{"newer version", header(ArchiveVersion+1, 0), ErrInvalidArchive},
+		{"request without id", header(1, 1) + `{"path":"/"}` + "\n", ErrInvalidArchive},
+		{"duplicate id", header(1, 2) + `{"id":"a"}` + "\n" + `{"id":"a"}` + "\n", ErrInvalidArchive},
+		{"truncated request", header(1, 1) + `{"id":"a",`, ErrInvalidArchive},
+		{"too many requests in the header", header(1, 3), ErrArchiveTooLarge},
+		{
+			"too many requests",
+			header(1, 0) + `{"id":"a"}` + "\n" + `{"id":"b"}` + "\n" + `{"id":"c"}` + "\n",
+			ErrArchiveTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(Config{Enabled: true, MaxRequests: 2})
+			n, err := d.Import(strings.NewReader(tt.archive))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Import() error = %v, want %v", err, tt.want)
+			}
+			if n != 0 || len(d.GetHistory()) != 0 {
+				t.Errorf("an invalid archive imported %d requests", n)
+			}
+		})
+	}
+}
+
+func TestArchiveReaderLimit(t *testing.T) {
+	r := &archiveReader{r: strings.NewReader("0123456789"), remaining: 4}
+	data, err := io.ReadAll(r)
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Errorf("ReadAll() error = %v, want ErrArchiveTooLarge", err)
+	}
+	if string(data) != "0123" {
+		t.Errorf("ReadAll() = %q, want the first 4 bytes", data)
+	}
+
+	r = &archiveReader{r: strings.NewReader("0123"), remaining: 4}
+	if data, err := io.ReadAll(r); err != nil || string(data) != "0123" {
+		t.Errorf("ReadAll() = %q, %v, want the whole input", data, err)
+	}
+}
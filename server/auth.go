@@ -0,0 +1,228 @@
+package godebugbar
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Errors returned by authenticators. Failures wrapping ErrUnauthenticated
+// are answered with 401 Unauthorized, anything else with 403 Forbidden.
+var (
+	ErrUnauthenticated = errors.New("missing or invalid credentials")
+	ErrForbidden       = errors.New("access denied")
+
+	// errBasicAuth marks failures that should prompt for basic credentials
+	errBasicAuth = fmt.Errorf("%w: bad username or password", ErrUnauthenticated)
+)
+
+// Authenticator decides whether a client may connect to the debug bar
+// endpoints. It returns nil to accept the request.
+type Authenticator interface {
+	Authenticate(r *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface
+type AuthenticatorFunc func(r *http.Request) error
+
+// Authenticate calls f(r)
+func (f AuthenticatorFunc) Authenticate(r *http.Request) error {
+	return f(r)
+}
+
+// AllowAll accepts every client. Only use it when the debug bar is
+// protected some other way.
+func AllowAll() Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		return nil
+	})
+}
+
+// LocalhostOnly accepts clients connecting from a loopback address. This is
+// the default when Config.Auth is nil. Behind a reverse proxy on the same
+// machine every client connects from loopback, so it accepts everyone; use
+// IPAllowlist or TokenAuth there instead.
+func LocalhostOnly() Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		addr, err := remoteAddr(r)
+		if err != nil || !addr.IsLoopback() {
+			return fmt.Errorf("%w: %s is not a loopback address", ErrForbidden, r.RemoteAddr)
+		}
+		return nil
+	})
+}
+
+// IPAllowlist accepts clients whose address is in one of the given IPs or
+// CIDR ranges, such as "10.0.0.0/8" or "192.168.1.20". The connection's
+// remote address is used, forwarding headers are ignored.
+func IPAllowlist(ranges ...string) (Authenticator, error) {
+	prefixes := make([]netip.Prefix, 0, len(ranges))
+	for _, value := range ranges {
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP %q: %w", value, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return AuthenticatorFunc(func(r *http.Request) error {
+		addr, err := remoteAddr(r)
+		if err == nil {
+			for _, prefix := range prefixes {
+				if prefix.Contains(addr) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: %s is not in the allowlist", ErrForbidden, r.RemoteAddr)
+	}), nil
+}
+
+// TokenAuth accepts clients presenting the given token, either as an
+// "Authorization: Bearer" header or, since browsers can't set headers on
+// WebSocket connections, a "token" query parameter
+func TokenAuth(token string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		presented := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+			presented = auth[7:]
+		}
+		if token == "" || !secureCompare(presented, token) {
+			return fmt.Errorf("%w: bad token", ErrUnauthenticated)
+		}
+		return nil
+	})
+}
+
+// BasicAuth accepts clients presenting the given HTTP basic credentials
+func BasicAuth(username, password string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		user, pass, ok := r.BasicAuth()
+		// Compare both so a wrong username takes as long as a wrong password
+		userOK := secureCompare(user, username)
+		passOK := secureCompare(pass, password)
+		if !ok || !userOK || !passOK {
+			return errBasicAuth
+		}
+		return nil
+	})
+}
+
+// AllOf accepts a client only if every authenticator does, for example an
+// IP allowlist combined with a token
+func AllOf(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		for _, auth := range authenticators {
+			if err := auth.Authenticate(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AnyOf accepts a client if any authenticator does, reporting the last
+// failure otherwise
+func AnyOf(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		err := fmt.Errorf("%w: no authenticators configured", ErrForbidden)
+		for _, auth := range authenticators {
+			if err = auth.Authenticate(r); err == nil {
+				return nil
+			}
+		}
+		return err
+	})
+}
+
+// RejectedConnections returns the number of clients refused by the
+// authenticator since the debug bar was created
+func (d *DebugBar) RejectedConnections() int64 {
+	return d.rejected.Load()
+}
+
+// authenticate checks a request against the configured authenticator,
+// writing an error response and returning false when it is refused
+func (d *DebugBar) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	err := d.auth.Authenticate(r)
+	if err == nil {
+		return true
+	}
+
+	d.rejected.Add(1)
+	d.logger.Printf("Debug bar rejected connection from %s: %v", r.RemoteAddr, err)
+
+	if errors.Is(err, ErrUnauthenticated) {
+		if errors.Is(err, errBasicAuth) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="debugbar"`)
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	return false
+}
+
+// remoteAddr parses the IP address of the connection that made r
+func remoteAddr(r *http.Request) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
+
+// secureCompare compares two strings in constant time
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// originAllowed reports whether a browser on the request's Origin may use
+// the debug bar. Requests without an Origin don't come from a page and are
+// allowed. Otherwise the origin must be listed in Config.AllowedOrigins, or
+// when none are configured, be the debug bar's own host or a loopback host.
+func (d *DebugBar) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(d.config.AllowedOrigins) > 0 {
+		for _, allowed := range d.config.AllowedOrigins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.IsLoopback()
+}
@@ -0,0 +1,202 @@
+package godebugbar
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// authRequest builds a request from remoteAddr with optional headers
+func authRequest(remoteAddr string, header ...string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	return req
+}
+
+func TestAuthenticators(t *testing.T) {
+	allowlist, err := IPAllowlist("10.0.0.0/8", "192.168.1.20", "2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := TokenAuth("s3cret")
+	basic := BasicAuth("admin", "pw")
+	basicReq := authRequest("1.2.3.4:1")
+	basicReq.SetBasicAuth("admin", "pw")
+	wrongBasic := authRequest("1.2.3.4:1")
+	wrongBasic.SetBasicAuth("admin", "nope")
+	queryToken := httptest.NewRequest(http.MethodGet, "/ws?token=s3cret", nil)
+
+	tests := []struct {
+		name string
+		auth Authenticator
+		req  *http.Request
+		err  error
+	}{
+		{"allow all", AllowAll(), authRequest("8.8.8.8:1"), nil},
+		{"localhost IPv4", LocalhostOnly(), authRequest("127.0.0.1:5000"), nil},
+		{"localhost IPv6", LocalhostOnly(), authRequest("[::1]:5000"), nil},
+		{"localhost mapped IPv4", LocalhostOnly(), authRequest("[::ffff:127.0.0.1]:5000"), nil},
+		{"remote client", LocalhostOnly(), authRequest("8.8.8.8:1"), ErrForbidden},
+		{"garbage address", LocalhostOnly(), authRequest("nowhere"), ErrForbidden},
+		{"allowlisted range", allowlist, authRequest("10.1.2.3:1"), nil},
+		{"allowlisted IP", allowlist, authRequest("192.168.1.20:1"), nil},
+		{"allowlisted IPv6 range", allowlist, authRequest("[2001:db8::1]:1"), nil},
+		{"neighbouring IP", allowlist, authRequest("192.168.1.21:1"), ErrForbidden},
+		{"forwarding headers ignored", allowlist, authRequest("8.8.8.8:1", "X-Forwarded-For", "10.0.0.1"), ErrForbidden},
+		{"bearer token", token, authRequest("8.8.8.8:1", "Authorization", "Bearer s3cret"), nil},
+		{"lowercase bearer", token, authRequest("8.8.8.8:1", "Authorization", "bearer s3cret"), nil},
+		{"query token", token, queryToken, nil},
+		{"wrong token", token, authRequest("8.8.8.8:1", "Authorization", "Bearer nope"), ErrUnauthenticated},
+		{"missing token", token, authRequest("8.8.8.8:1"), ErrUnauthenticated},
+		{"empty configured token", TokenAuth(""), authRequest("8.8.8.8:1", "Authorization", "Bearer "), ErrUnauthenticated},
+		{"basic", basic, basicReq, nil},
+		{"wrong basic", basic, wrongBasic, errBasicAuth},
+		{"missing basic", basic, authRequest("1.2.3.4:1"), errBasicAuth},
+		{"all of", AllOf(allowlist, token), authRequest("10.0.0.1:1", "Authorization", "Bearer s3cret"), nil},
+		{"all of, one failing", AllOf(allowlist, token), authRequest("10.0.0.1:1"), ErrUnauthenticated},
+		{"any of", AnyOf(LocalhostOnly(), token), authRequest("8.8.8.8:1", "Authorization", "Bearer s3cret"), nil},
+		{"any of, all failing", AnyOf(token, LocalhostOnly()), authRequest("8.8.8.8:1"), ErrForbidden},
+		{"any of nothing", AnyOf(), authRequest("127.0.0.1:1"), ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.Authenticate(tt.req)
+			if tt.err == nil && err != nil {
+				t.Errorf("Authenticate() error = %v, want nil", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Authenticate() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestIPAllowlistInvalid(t *testing.T) {
+	for _, value := range []string{"10.0.0.0/33", "not-an-ip", "1.2.3"} {
+		if _, err := IPAllowlist(value); err == nil {
+			t.Errorf("IPAllowlist(%q) succeeded", value)
+		}
+	}
+}
+
+func TestAuthenticateResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		auth      Authenticator
+		req       *http.Request
+		status    int
+		challenge bool
+	}{
+		{"accepted", AllowAll(), authRequest("8.8.8.8:1"), http.StatusOK, false},
+		{"forbidden", LocalhostOnly(), authRequest("8.8.8.8:1"), http.StatusForbidden, false},
+		{"unauthenticated", TokenAuth("s3cret"), authRequest("8.8.8.8:1"), http.StatusUnauthorized, false},
+		{"basic challenge", BasicAuth("admin", "pw"), authRequest("8.8.8.8:1"), http.StatusUnauthorized, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewWithOptions(WithConfig(testConfig()), WithAuth(tt.auth), WithLogger(log.New(io.Discard, "", 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			d.Handler().ServeHTTP(w, tt.req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if challenge := w.Header().Get("WWW-Authenticate") != ""; challenge != tt.challenge {
+				t.Errorf("WWW-Authenticate set = %v, want %v", challenge, tt.challenge)
+			}
+			wantRejected := int64(0)
+			if tt.status != http.StatusOK {
+				wantRejected = 1
+			}
+			if got := d.RejectedConnections(); got != wantRejected {
+				t.Errorf("RejectedConnections() = %d, want %d", got, wantRejected)
+			}
+		})
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		host    string
+		origin  string
+		want    bool
+	}{
+		{"no origin", nil, "app.test", "", true},
+		{"same host", nil, "app.test:8080", "http://app.test:8080", true},
+		{"localhost page", nil, "app.test", "http://localhost:3000", true},
+		{"loopback page", nil, "app.test", "http://127.0.0.1:3000", true},
+		{"other site", nil, "app.test", "https://evil.test", false},
+		{"same name, other port", nil, "app.test:8080", "http://app.test:9090", false},
+		{"bad origin", nil, "app.test", "null", false},
+		{"listed origin", []string{"https://tools.test"}, "app.test", "https://TOOLS.test", true},
+		{"unlisted origin", []string{"https://tools.test"}, "localhost", "http://localhost:3000", false},
+		{"wildcard", []string{"*"}, "app.test", "https://evil.test", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AllowedOrigins = tt.allowed
+			d := New(config)
+
+			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := d.originAllowed(req); got != tt.want {
+				t.Errorf("originAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	d, err := NewWithOptions(WithConfig(testConfig()), WithAuth(TokenAuth("s3cret")), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := testEngine(t, d)
+	d.RegisterRoutes(engine)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + d.config.WebSocketPath
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial without a token: %v, want 401", err)
+	}
+	if got := d.RejectedConnections(); got != 1 {
+		t.Errorf("RejectedConnections() = %d, want 1", got)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?token=s3cret", nil)
+	if err != nil {
+		t.Fatalf("dial with the token: %v", err)
+	}
+	conn.Close()
+
+	// A page on another site is refused even with the token
+	header := http.Header{"Origin": {"https://evil.test"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?token=s3cret", header); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("dial from another origin: %v, want 403", err)
+	}
+	if got := d.RejectedConnections(); got != 1 {
+		t.Errorf("RejectedConnections() = %d, want only the unauthenticated dial", got)
+	}
+}
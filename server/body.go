@@ -0,0 +1,93 @@
+package godebugbar
+
+import (
+	"bytes"
+	"io"
+)
+
+// bodyCapture wraps a request body so the handler still reads the complete
+// original stream while the debug bar keeps a copy of the first limit bytes
+type bodyCapture struct {
+	reader    io.Reader
+	closer    io.Closer
+	captured  []byte
+	truncated bool
+	prefixLen int64
+	read      int64
+	eof       bool
+
+	// parts, when set, sees everything the handler reads
+	parts *partSizer
+}
+
+// captureBody reads up to limit bytes from body for display and returns a
+// replacement body that replays those bytes followed by the rest of the stream
+func captureBody(body io.ReadCloser, limit int) *bodyCapture {
+	// Read one extra byte so we can tell whether the body was cut off
+	prefix, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+
+	rest := io.Reader(body)
+	if err != nil {
+		// Replay what was read, then give the handler the same error
+		// instead of silently continuing from the underlying reader
+		rest = &errorReader{err: err}
+	}
+
+	capture := &bodyCapture{
+		reader:    io.MultiReader(bytes.NewReader(prefix), rest),
+		closer:    body,
+		prefixLen: int64(len(prefix)),
+	}
+
+	if len(prefix) > limit {
+		capture.captured = prefix[:limit]
+		capture.truncated = true
+	} else {
+		capture.captured = prefix
+		// A short read without an error means the whole body fit
+		capture.eof = err == nil
+		capture.read = int64(len(prefix))
+	}
+
+	return capture
+}
+
+func (b *bodyCapture) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if b.parts != nil && n > 0 {
+		b.parts.Write(p[:n])
+	}
+	if b.truncated {
+		b.read += int64(n)
+		if err == io.EOF {
+			b.eof = true
+		}
+	}
+	return n, err
+}
+
+// errorReader fails every read with err
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func (b *bodyCapture) Close() error {
+	return b.closer.Close()
+}
+
+// totalSize returns the size of the complete body. When the handler did not
+// read the body to the end, the declared content length is used if known,
+// otherwise the number of bytes seen so far.
+func (b *bodyCapture) totalSize(contentLength int64) int64 {
+	if b.eof {
+		return b.read
+	}
+	if contentLength >= 0 {
+		return contentLength
+	}
+	return max(b.read, b.prefixLen)
+}
@@ -0,0 +1,79 @@
+package godebugbar
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testBody is a request body that records whether it was closed
+type testBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *testBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestCaptureBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		limit         int
+		read          bool
+		contentLength int64
+		captured      string
+		truncated     bool
+		size          int64
+	}{
+		{"fits", "hello", 10, true, -1, "hello", false, 5},
+		{"exactly the limit", "hello", 5, true, -1, "hello", false, 5},
+		{"truncated", "hello world", 5, true, -1, "hello", true, 11},
+		{"truncated and unread", "hello world", 5, false, 11, "hello", true, 11},
+		{"truncated, unread, unknown length", "hello world", 5, false, -1, "hello", true, 6},
+		{"unread but fits", "hello", 10, false, -1, "hello", false, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &testBody{Reader: strings.NewReader(tt.body)}
+			capture := captureBody(body, tt.limit)
+
+			if string(capture.captured) != tt.captured || capture.truncated != tt.truncated {
+				t.Errorf("captured %q (truncated %v), want %q (truncated %v)", capture.captured, capture.truncated, tt.captured, tt.truncated)
+			}
+			if tt.read {
+				// The handler still reads the whole original body
+				data, err := io.ReadAll(capture)
+				if err != nil || string(data) != tt.body {
+					t.Errorf("handler read %q, %v, want %q", data, err, tt.body)
+				}
+			}
+			if size := capture.totalSize(tt.contentLength); size != tt.size {
+				t.Errorf("totalSize() = %d, want %d", size, tt.size)
+			}
+
+			capture.Close()
+			if !body.closed {
+				t.Error("Close() didn't close the original body")
+			}
+		})
+	}
+}
+
+func TestCaptureBodyReadError(t *testing.T) {
+	failure := errors.New("connection reset")
+	body := &testBody{Reader: io.MultiReader(strings.NewReader("partial"), &errorReader{err: failure})}
+	capture := captureBody(body, 100)
+
+	if string(capture.captured) != "partial" || capture.totalSize(-1) != 7 {
+		t.Errorf("captured %q of %d bytes, want the partial body", capture.captured, capture.totalSize(-1))
+	}
+	data, err := io.ReadAll(capture)
+	if string(data) != "partial" || !errors.Is(err, failure) {
+		t.Errorf("handler read %q, %v, want the partial body and the read error", data, err)
+	}
+}
@@ -0,0 +1,206 @@
+package godebugbar
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CaptureRule decides whether matching requests are captured and which
+// bodies are kept. A rule matches when every condition that is set
+// matches; a rule with no conditions matches every request.
+type CaptureRule struct {
+	// Path is a glob matched against the request path. "*" matches within
+	// a path segment and "**" across segments, e.g. "/static/**".
+	Path string
+
+	// Pattern is a regular expression matched against the request path
+	Pattern *regexp.Regexp
+
+	// Route is a Gin route pattern such as "/users/:id"
+	Route string
+
+	// Methods limits the rule to these HTTP methods
+	Methods []string
+
+	// Skip excludes matching requests from capture
+	Skip bool
+
+	// SampleRate is the fraction of matching requests captured, between 0
+	// and 1. Zero captures every request; use Skip to capture none.
+	SampleRate float64
+
+	// CaptureRequestBody and CaptureResponseBody override the global body
+	// capture settings for matching requests when set
+	CaptureRequestBody  *bool
+	CaptureResponseBody *bool
+}
+
+// captureRule is a CaptureRule with its path glob compiled
+type captureRule struct {
+	CaptureRule
+	glob *regexp.Regexp
+}
+
+// captureDecision is the outcome of evaluating the capture rules
+type captureDecision struct {
+	capture      bool
+	requestBody  bool
+	responseBody bool
+}
+
+// compileCaptureRules compiles the path globs of rules
+func compileCaptureRules(rules []CaptureRule) []captureRule {
+	compiled := make([]captureRule, len(rules))
+	for i, rule := range rules {
+		compiled[i] = captureRule{CaptureRule: rule}
+		if rule.Path != "" {
+			compiled[i].glob = globPattern(rule.Path)
+		}
+	}
+	return compiled
+}
+
+// globPattern converts a path glob into an anchored regular expression
+func globPattern(glob string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			pattern.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			pattern.WriteString("[^/]*")
+		case glob[i] == '?':
+			pattern.WriteString("[^/]")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// matches reports whether the rule applies to a request
+func (r *captureRule) matches(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	if r.glob != nil && !r.glob.MatchString(path) {
+		return false
+	}
+	if r.Pattern != nil && !r.Pattern.MatchString(path) {
+		return false
+	}
+	if r.Route != "" && r.Route != c.FullPath() {
+		return false
+	}
+	if len(r.Methods) > 0 {
+		found := false
+		for _, method := range r.Methods {
+			if strings.EqualFold(method, c.Request.Method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// captureDecision evaluates the capture rules for a request. The first
+// matching rule decides; requests matching no rule are captured with the
+// global body settings.
+func (d *DebugBar) captureDecision(c *gin.Context) captureDecision {
+	decision := captureDecision{
+		capture:      true,
+		requestBody:  d.config.CaptureRequestBody,
+		responseBody: d.config.CaptureResponseBody,
+	}
+
+	for i := range d.captureRules {
+		rule := &d.captureRules[i]
+		if !rule.matches(c) {
+			continue
+		}
+
+		if rule.Skip || (rule.SampleRate > 0 && rand.Float64() >= rule.SampleRate) {
+			decision.capture = false
+		}
+		if rule.CaptureRequestBody != nil {
+			decision.requestBody = *rule.CaptureRequestBody
+		}
+		if rule.CaptureResponseBody != nil {
+			decision.responseBody = *rule.CaptureResponseBody
+		}
+		break
+	}
+
+	return decision
+}
+
+// captureSkipped records a request the rules skipped when it ended with a
+// server error or Gin errors and the matching AlwaysCapture setting is on.
+// Queries, bodies and handler timings weren't tracked, so the request is
+// marked as partial.
+func (d *DebugBar) captureSkipped(c *gin.Context, startTime time.Time, activatedBy string) {
+	status := c.Writer.Status()
+	serverError := d.config.AlwaysCaptureServerErrors && status >= 500
+	hasErrors := d.config.AlwaysCaptureErrors && len(c.Errors) > 0
+	if !serverError && !hasErrors {
+		return
+	}
+
+	endTime := d.clock()
+	reqInfo := &RequestInfo{
+		ID:              uuid.New().String(),
+		Method:          c.Request.Method,
+		Path:            c.Request.URL.Path,
+		Route:           c.FullPath(),
+		HandlerName:     c.HandlerName(),
+		Unmatched:       c.FullPath() == "",
+		StatusCode:      status,
+		StartTime:       startTime,
+		EndTime:         endTime,
+		Duration:        endTime.Sub(startTime),
+		DurationMs:      float64(endTime.Sub(startTime).Nanoseconds()) / 1e6,
+		RequestHeaders:  d.redactor.RedactHeaders(headerFields(c.Request.Header)),
+		QueryValues:     d.redactor.RedactQuery(queryFields(c.Request.URL.RawQuery)),
+		ResponseHeaders: d.redactor.RedactHeaders(headerFields(c.Writer.Header())),
+		ResponseSize:    max(c.Writer.Size(), 0),
+		ClientIP:        c.ClientIP(),
+		ActivatedBy:     activatedBy,
+		Queries:         make([]QueryInfo, 0),
+		Errors:          make([]ErrorInfo, 0, len(c.Errors)),
+		Partial:         true,
+	}
+	if !d.config.MultiValueOnly {
+		reqInfo.Headers = reqInfo.RequestHeaders.Flatten()
+		reqInfo.QueryParams = reqInfo.QueryValues.Flatten()
+	}
+
+	for _, ginErr := range c.Errors {
+		reqInfo.Errors = append(reqInfo.Errors, ErrorInfo{
+			ID:        uuid.New().String(),
+			RequestID: reqInfo.ID,
+			Message:   d.redactor.RedactString(ginErr.Error()),
+			Type:      ErrorTypeException,
+			Timestamp: endTime,
+			Context: d.redactor.RedactValues(d.encoder.EncodeMap(map[string]any{
+				"gin_error_type": ginErr.Type,
+				"gin_error_meta": ginErr.Meta,
+			})),
+		})
+	}
+
+	d.storeRequest(reqInfo)
+	d.broadcast(WebSocketMessage{
+		Type:    MessageTypeRequestEnd,
+		Payload: reqInfo,
+	})
+}
@@ -0,0 +1,154 @@
+package godebugbar
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGlobPattern(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"/health", "/health", true},
+		{"/health", "/healthz", false},
+		{"/static/*", "/static/app.js", true},
+		{"/static/*", "/static/js/app.js", false},
+		{"/static/**", "/static/js/app.js", true},
+		{"/users/*/avatar", "/users/42/avatar", true},
+		{"/v?/users", "/v2/users", true},
+		{"/v?/users", "/v/users", false},
+		{"/file.txt", "/fileAtxt", false},
+	}
+
+	for _, tt := range tests {
+		if got := globPattern(tt.glob).MatchString(tt.path); got != tt.match {
+			t.Errorf("glob %q matching %q = %v, want %v", tt.glob, tt.path, got, tt.match)
+		}
+	}
+}
+
+func TestCaptureRules(t *testing.T) {
+	off := false
+	config := testConfig()
+	config.CaptureRules = []CaptureRule{
+		{Path: "/static/**", Skip: true},
+		{Pattern: regexp.MustCompile(`^/internal/`), Skip: true},
+		{Route: "/users/:id", Methods: []string{"delete"}, Skip: true},
+		{Path: "/upload", CaptureRequestBody: &off},
+		// Never reached for /static, the first matching rule decides
+		{Path: "/static/keep.js"},
+	}
+	d := New(config)
+	engine := testEngine(t, d)
+	engine.NoRoute(func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	engine.Handle(http.MethodGet, "/users/:id", func(c *gin.Context) {})
+	engine.Handle(http.MethodDelete, "/users/:id", func(c *gin.Context) {})
+
+	tests := []struct {
+		method  string
+		path    string
+		capture bool
+	}{
+		{http.MethodGet, "/static/css/site.css", false},
+		{http.MethodGet, "/static/keep.js", false},
+		{http.MethodGet, "/internal/metrics", false},
+		{http.MethodDelete, "/users/1", false},
+		{http.MethodGet, "/users/1", true},
+		{http.MethodGet, "/other", true},
+	}
+	for _, tt := range tests {
+		if _, captured := serve(t, d, engine, httptest.NewRequest(tt.method, tt.path, nil)); (captured != nil) != tt.capture {
+			t.Errorf("%s %s captured = %v, want %v", tt.method, tt.path, captured != nil, tt.capture)
+		}
+	}
+
+	_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("secret")))
+	if captured == nil || captured.RequestBody != "" || captured.ResponseBody != "ok" {
+		t.Errorf("captured %+v, want the response body only", captured)
+	}
+}
+
+func TestCaptureSampling(t *testing.T) {
+	tests := []struct {
+		rate     float64
+		min, max int
+	}{
+		{0, 1000, 1000},
+		{1, 1000, 1000},
+		{0.5, 350, 650},
+		{0.01, 0, 50},
+	}
+
+	for _, tt := range tests {
+		config := testConfig()
+		config.MaxRequests = 1000
+		config.CaptureRules = []CaptureRule{{Path: "/**", SampleRate: tt.rate}}
+		d := New(config)
+		engine := testEngine(t, d)
+		engine.GET("/", func(c *gin.Context) {})
+
+		for range 1000 {
+			engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+		if got := len(d.GetHistory()); got < tt.min || got > tt.max {
+			t.Errorf("SampleRate %g captured %d of 1000, want %d to %d", tt.rate, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestAlwaysCapture(t *testing.T) {
+	tests := []struct {
+		name         string
+		serverErrors bool
+		errors       bool
+		path         string
+		capture      bool
+	}{
+		{"skipped success", true, true, "/ok", false},
+		{"server error", true, false, "/fail", true},
+		{"server error not wanted", false, true, "/fail", false},
+		{"gin error", false, true, "/error", true},
+		{"gin error not wanted", true, false, "/error", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.CaptureRules = []CaptureRule{{Path: "/**", Skip: true}}
+			config.AlwaysCaptureServerErrors = tt.serverErrors
+			config.AlwaysCaptureErrors = tt.errors
+			d := New(config)
+			engine := testEngine(t, d)
+			engine.GET("/ok", func(c *gin.Context) {})
+			engine.GET("/fail", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+			engine.GET("/error", func(c *gin.Context) {
+				c.Error(errors.New("token=abc failed"))
+				c.Status(http.StatusBadRequest)
+			})
+
+			_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodGet, tt.path+"?q=1", nil))
+			if (captured != nil) != tt.capture {
+				t.Fatalf("captured = %v, want %v", captured != nil, tt.capture)
+			}
+			if captured == nil {
+				return
+			}
+			if !captured.Partial || captured.Route != tt.path || captured.QueryValues.Get("q") != "1" {
+				t.Errorf("captured %+v, want a partial record of the request", captured)
+			}
+			if tt.path == "/error" && (len(captured.Errors) != 1 || captured.Errors[0].Message != "token=abc failed") {
+				t.Errorf("Errors = %+v, want the Gin error", captured.Errors)
+			}
+		})
+	}
+}
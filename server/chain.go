@@ -0,0 +1,126 @@
+package godebugbar
+
+import (
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandlerTiming records when a handler in the Gin chain ran and for how long
+type HandlerTiming struct {
+	Name       string        `json:"name"`
+	Depth      int           `json:"depth"`
+	Offset     time.Duration `json:"offset"`
+	OffsetMs   float64       `json:"offset_ms"`
+	Duration   time.Duration `json:"duration"`
+	DurationMs float64       `json:"duration_ms"`
+
+	// SelfDuration excludes time spent in timed handlers it called via c.Next()
+	SelfDuration   time.Duration `json:"self_duration"`
+	SelfDurationMs float64       `json:"self_duration_ms"`
+
+	// Aborted is set on the handler that called c.Abort()
+	Aborted bool `json:"aborted,omitempty"`
+}
+
+// handlerChain tracks the timed handlers currently running for a request
+type handlerChain struct {
+	stack    []int
+	children []time.Duration
+}
+
+// WrapEngine instruments the Gin chain of router so each handler's timing is
+// recorded on the request. It wraps the global middleware registered so far
+// and adds a final middleware that times the rest of each route's chain,
+// reported under the route's handler name. Call it after router.Use() and
+// before registering routes; group and route middleware are timed
+// separately when wrapped with WrapHandler.
+func (d *DebugBar) WrapEngine(router *gin.Engine) {
+	for i, handler := range router.Handlers {
+		router.Handlers[i] = d.wrapHandler(nameOfHandler(handler), handler)
+	}
+	router.Use(d.wrapHandler("", func(c *gin.Context) {
+		c.Next()
+	}))
+}
+
+// WrapHandler instruments a single handler, for timing group and route
+// middleware. Wrapping a route's final handler changes the name Gin reports
+// for it, so leave those to WrapEngine.
+func (d *DebugBar) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc {
+	return d.wrapHandler(nameOfHandler(handler), handler)
+}
+
+// wrapHandler returns a handler that records the timing of handler. An empty
+// name is resolved to the route's handler name when the handler runs.
+func (d *DebugBar) wrapHandler(name string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqInfo := d.GetRequestInfo(c)
+		if reqInfo == nil {
+			// Not tracked, or this is the debug bar middleware itself which
+			// creates the request info
+			handler(c)
+			return
+		}
+
+		label := name
+		if label == "" {
+			label = c.HandlerName()
+		}
+
+		start := d.clock()
+		wasAborted := c.IsAborted()
+
+		d.mu.Lock()
+		if reqInfo.chain == nil {
+			reqInfo.chain = &handlerChain{}
+		}
+		chain := reqInfo.chain
+		index := len(reqInfo.Handlers)
+		reqInfo.Handlers = append(reqInfo.Handlers, HandlerTiming{
+			Name:     label,
+			Depth:    len(chain.stack),
+			Offset:   start.Sub(reqInfo.StartTime),
+			OffsetMs: float64(start.Sub(reqInfo.StartTime).Nanoseconds()) / 1e6,
+		})
+		chain.stack = append(chain.stack, index)
+		chain.children = append(chain.children, 0)
+		d.mu.Unlock()
+
+		handler(c)
+
+		elapsed := d.clock().Sub(start)
+
+		d.mu.Lock()
+		childTime := chain.children[len(chain.children)-1]
+		chain.stack = chain.stack[:len(chain.stack)-1]
+		chain.children = chain.children[:len(chain.children)-1]
+		if len(chain.children) > 0 {
+			chain.children[len(chain.children)-1] += elapsed
+		}
+
+		timing := &reqInfo.Handlers[index]
+		timing.Duration = elapsed
+		timing.DurationMs = float64(elapsed.Nanoseconds()) / 1e6
+		timing.SelfDuration = elapsed - childTime
+		timing.SelfDurationMs = float64(timing.SelfDuration.Nanoseconds()) / 1e6
+
+		// Inner handlers finish first, so the first one to see the abort
+		// is the one that caused it
+		if !wasAborted && c.IsAborted() && reqInfo.AbortedBy == "" {
+			timing.Aborted = true
+			reqInfo.AbortedBy = label
+		}
+		d.mu.Unlock()
+	}
+}
+
+// nameOfHandler returns the function name of a handler
+func nameOfHandler(handler gin.HandlerFunc) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
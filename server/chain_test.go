@@ -0,0 +1,133 @@
+package godebugbar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testClock is a clock that only moves when a test advances it
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// chainTestEngine returns an engine with a timed global middleware that
+// calls c.Next(), a group middleware wrapped with WrapHandler and a route
+func chainTestEngine(t *testing.T, group gin.HandlerFunc) (*DebugBar, *gin.Engine, *testClock, gin.HandlerFunc) {
+	t.Helper()
+	clock := newTestClock()
+	d, err := NewWithOptions(WithConfig(testConfig()), WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	global := func(c *gin.Context) {
+		clock.advance(2 * time.Millisecond)
+		c.Next()
+	}
+	engine := testEngine(t, d)
+	engine.Use(global)
+	d.WrapEngine(engine)
+
+	engine.Group("/api", d.WrapHandler(group)).GET("/users", func(c *gin.Context) {
+		clock.advance(5 * time.Millisecond)
+	})
+	return d, engine, clock, global
+}
+
+func TestHandlerTiming(t *testing.T) {
+	var clock *testClock
+	group := func(c *gin.Context) {
+		clock.advance(3 * time.Millisecond)
+	}
+	d, engine, clock, global := chainTestEngine(t, group)
+
+	_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	if captured == nil {
+		t.Fatal("request wasn't captured")
+	}
+
+	ms := time.Millisecond
+	want := []HandlerTiming{
+		{Name: nameOfHandler(global), Depth: 0, Offset: 0, Duration: 10 * ms, SelfDuration: 2 * ms},
+		{Name: captured.HandlerName, Depth: 1, Offset: 2 * ms, Duration: 8 * ms, SelfDuration: 5 * ms},
+		{Name: nameOfHandler(group), Depth: 2, Offset: 2 * ms, Duration: 3 * ms, SelfDuration: 3 * ms},
+	}
+	if len(captured.Handlers) != len(want) {
+		t.Fatalf("Handlers = %+v, want %d entries", captured.Handlers, len(want))
+	}
+	for i, got := range captured.Handlers {
+		w := want[i]
+		if got.Name != w.Name || got.Depth != w.Depth || got.Offset != w.Offset || got.Duration != w.Duration || got.SelfDuration != w.SelfDuration {
+			t.Errorf("Handlers[%d] = %+v, want %+v", i, got, w)
+		}
+		if got.DurationMs != float64(w.Duration)/1e6 || got.Aborted {
+			t.Errorf("Handlers[%d] = %+v, want %v ms and not aborted", i, got, float64(w.Duration)/1e6)
+		}
+	}
+	if captured.HandlerName == nameOfHandler(group) || captured.AbortedBy != "" {
+		t.Errorf("HandlerName = %q, AbortedBy = %q, want the route's handler and no abort", captured.HandlerName, captured.AbortedBy)
+	}
+}
+
+func TestHandlerTimingAbort(t *testing.T) {
+	deny := func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+	d, engine, _, _ := chainTestEngine(t, deny)
+
+	w, captured := serve(t, d, engine, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	if w.Code != http.StatusForbidden || captured == nil {
+		t.Fatalf("status %d, captured %v, want a captured 403", w.Code, captured)
+	}
+	if captured.AbortedBy != nameOfHandler(deny) {
+		t.Errorf("AbortedBy = %q, want %q", captured.AbortedBy, nameOfHandler(deny))
+	}
+	for _, timing := range captured.Handlers {
+		if timing.Aborted != (timing.Name == nameOfHandler(deny)) {
+			t.Errorf("%s has Aborted = %v, want only the aborting handler flagged", timing.Name, timing.Aborted)
+		}
+	}
+}
+
+func TestWrapHandlerUntracked(t *testing.T) {
+	d := New(testConfig())
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+
+	ran := false
+	engine.GET("/", d.WrapHandler(func(c *gin.Context) {
+		ran = true
+		c.Status(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !ran || w.Code != http.StatusOK {
+		t.Errorf("wrapped handler ran = %v with status %d outside the debug bar", ran, w.Code)
+	}
+	if len(d.GetHistory()) != 0 {
+		t.Error("an untracked request was captured")
+	}
+}
@@ -0,0 +1,226 @@
+package godebugbar
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Collector gathers extra data about a request. Collectors run on the request
+// goroutine after the handler chain has finished and before the request is
+// stored, so they may modify req directly.
+type Collector interface {
+	Collect(c *gin.Context, req *RequestInfo)
+}
+
+// CollectorFunc adapts a function to the Collector interface
+type CollectorFunc func(c *gin.Context, req *RequestInfo)
+
+// Collect calls f(c, req)
+func (f CollectorFunc) Collect(c *gin.Context, req *RequestInfo) {
+	f(c, req)
+}
+
+// DefaultCollectors returns the built-in collectors
+func DefaultCollectors() []Collector {
+	return []Collector{
+		NewContextKeysCollector(),
+		NewValidationCollector(),
+		NewCookieCollector(),
+	}
+}
+
+// ValidationError describes a single failed validation rule from binding
+type ValidationError struct {
+	Field     string `json:"field"`
+	Namespace string `json:"namespace"`
+	Tag       string `json:"tag"`
+	Param     string `json:"param,omitempty"`
+	Value     any    `json:"value"`
+	Message   string `json:"message"`
+}
+
+// ContextKeysCollector snapshots the values set on the Gin context with
+// c.Set, such as user IDs, tenant IDs and feature flags
+type ContextKeysCollector struct {
+	exclude map[string]bool
+}
+
+// NewContextKeysCollector creates a collector for c.Keys, skipping the given
+// keys in addition to the debug bar's own
+func NewContextKeysCollector(exclude ...string) *ContextKeysCollector {
+	collector := &ContextKeysCollector{
+		exclude: map[string]bool{
+			string(DebugBarContextKey): true,
+			dbContextKey:               true,
+		},
+	}
+	for _, key := range exclude {
+		collector.exclude[key] = true
+	}
+	return collector
+}
+
+// Collect implements Collector
+func (k *ContextKeysCollector) Collect(c *gin.Context, req *RequestInfo) {
+	// Copy takes the context's lock, so handlers still calling c.Set from
+	// other goroutines can't race with the snapshot
+	keys := make(map[string]any)
+	for key, value := range c.Copy().Keys {
+		name := fmt.Sprint(key)
+		if k.exclude[name] {
+			continue
+		}
+		keys[name] = value
+	}
+	if len(keys) > 0 {
+		req.ContextKeys = keys
+	}
+}
+
+// ValidationCollector records binding failures added to the context with
+// c.Error(err), where err holds validator.ValidationErrors. Errors returned
+// by c.ShouldBind never reach the context, bind with DebugBar.ShouldBind or
+// pass them to LogValidationErrors to record them.
+type ValidationCollector struct{}
+
+// NewValidationCollector creates a collector for binding validation errors
+func NewValidationCollector() *ValidationCollector {
+	return &ValidationCollector{}
+}
+
+// Collect implements Collector
+func (v *ValidationCollector) Collect(c *gin.Context, req *RequestInfo) {
+	for _, ginErr := range c.Errors {
+		req.ValidationErrors = appendValidationErrors(req.ValidationErrors, validationErrors(ginErr.Err))
+	}
+}
+
+// ShouldBind binds the request like c.ShouldBind and records any validation
+// failures on the request
+func (d *DebugBar) ShouldBind(c *gin.Context, obj any) error {
+	err := c.ShouldBind(obj)
+	d.LogValidationErrors(c, err)
+	return err
+}
+
+// ShouldBindWith binds the request like c.ShouldBindWith and records any
+// validation failures on the request
+func (d *DebugBar) ShouldBindWith(c *gin.Context, obj any, b binding.Binding) error {
+	err := c.ShouldBindWith(obj, b)
+	d.LogValidationErrors(c, err)
+	return err
+}
+
+// LogValidationErrors records the validation failures in err, typically the
+// error returned by c.ShouldBind. Other errors are logged as warnings.
+func (d *DebugBar) LogValidationErrors(c *gin.Context, err error) {
+	if !d.config.Enabled || err == nil {
+		return
+	}
+
+	entries := validationErrors(err)
+	if len(entries) == 0 {
+		d.logError(c, err, ErrorTypeWarning, nil, 2)
+		return
+	}
+
+	reqInfo := d.GetRequestInfo(c)
+	if reqInfo == nil {
+		return
+	}
+
+	d.mu.Lock()
+	reqInfo.ValidationErrors = appendValidationErrors(reqInfo.ValidationErrors, entries)
+	d.mu.Unlock()
+}
+
+// appendValidationErrors appends the entries not already recorded, so an
+// error both logged and added with c.Error is only listed once
+func appendValidationErrors(recorded, entries []ValidationError) []ValidationError {
+	for _, entry := range entries {
+		duplicate := slices.ContainsFunc(recorded, func(existing ValidationError) bool {
+			return existing.Namespace == entry.Namespace && existing.Tag == entry.Tag &&
+				existing.Param == entry.Param && existing.Message == entry.Message
+		})
+		if !duplicate {
+			recorded = append(recorded, entry)
+		}
+	}
+	return recorded
+}
+
+// validationErrors converts validator.ValidationErrors into entries
+func validationErrors(err error) []ValidationError {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+
+	entries := make([]ValidationError, 0, len(errs))
+	for _, fieldErr := range errs {
+		entries = append(entries, ValidationError{
+			Field:     fieldErr.Field(),
+			Namespace: fieldErr.Namespace(),
+			Tag:       fieldErr.Tag(),
+			Param:     fieldErr.Param(),
+			Value:     fieldErr.Value(),
+			Message:   fieldErr.Error(),
+		})
+	}
+	return entries
+}
+
+// runCollectors runs the configured collectors for a finished request and
+// makes the values they gathered safe to store
+func (d *DebugBar) runCollectors(c *gin.Context, req *RequestInfo) {
+	for _, collector := range d.collectors {
+		collector.Collect(c, req)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if req.ContextKeys != nil {
+		req.ContextKeys = d.redactor.RedactValues(d.encoder.EncodeMap(req.ContextKeys))
+	}
+	for i := range req.ValidationErrors {
+		req.ValidationErrors[i].Value = d.redactor.RedactValue(d.encoder.Encode(req.ValidationErrors[i].Value))
+		if d.redactor.IsSensitiveName(req.ValidationErrors[i].Field) {
+			req.ValidationErrors[i].Value = d.redactor.replacement
+		}
+	}
+	for i, cookie := range req.RequestCookies {
+		req.RequestCookies[i].Value = d.redactor.RedactCookie("Cookie", cookie.Name, cookie.Value)
+	}
+	for i, cookie := range req.ResponseCookies {
+		req.ResponseCookies[i].Value = d.redactor.RedactCookie("Set-Cookie", cookie.Name, cookie.Value)
+	}
+	if session := req.Session; session != nil {
+		session.Before = d.redactSession(session.Before)
+		session.After = d.redactSession(session.After)
+		for i, change := range session.Changes {
+			if d.redactor.IsSensitiveName(change.Key) {
+				session.Changes[i].Before, session.Changes[i].After = nil, nil
+				if change.Before != nil {
+					session.Changes[i].Before = d.redactor.replacement
+				}
+				if change.After != nil {
+					session.Changes[i].After = d.redactor.replacement
+				}
+				continue
+			}
+			session.Changes[i].Before = d.redactor.RedactValue(d.encoder.Encode(change.Before))
+			session.Changes[i].After = d.redactor.RedactValue(d.encoder.Encode(change.After))
+		}
+	}
+}
+
+// redactSession encodes a session snapshot and masks sensitive keys
+func (d *DebugBar) redactSession(values map[string]any) map[string]any {
+	return d.redactor.RedactValues(d.encoder.EncodeMap(values))
+}
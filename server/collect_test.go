@@ -0,0 +1,113 @@
+package godebugbar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestContextKeysCollector(t *testing.T) {
+	config := testConfig()
+	config.Collectors = []Collector{NewContextKeysCollector("internal")}
+	d := New(config)
+	engine := testEngine(t, d)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	engine.GET("/keys", func(c *gin.Context) {
+		c.Set("user_id", 42)
+		c.Set("internal", "skipped")
+		c.Set("background", true)
+
+		// A goroutine the handler started may still be setting keys while
+		// the collector runs
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					c.Set("background", true)
+				}
+			}
+		}()
+		c.Status(http.StatusOK)
+	})
+
+	_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	close(stop)
+	wg.Wait()
+	if captured == nil {
+		t.Fatal("request wasn't captured")
+	}
+	if fmt.Sprint(captured.ContextKeys["user_id"]) != "42" || captured.ContextKeys["background"] != true {
+		t.Errorf("ContextKeys = %v, want the handler's keys", captured.ContextKeys)
+	}
+	for _, key := range []string{"internal", string(DebugBarContextKey)} {
+		if _, ok := captured.ContextKeys[key]; ok {
+			t.Errorf("ContextKeys has excluded key %q", key)
+		}
+	}
+}
+
+func TestValidationCollector(t *testing.T) {
+	type signup struct {
+		Email string `form:"email" binding:"required,email"`
+		Age   int    `form:"age" binding:"gte=18"`
+	}
+
+	config := testConfig()
+	config.Collectors = []Collector{NewValidationCollector()}
+	d := New(config)
+	engine := testEngine(t, d)
+
+	engine.POST("/signup", func(c *gin.Context) {
+		var form signup
+		if err := d.ShouldBind(c, &form); err != nil {
+			// Adding the same error to the context doesn't list it twice
+			c.Error(err)
+			c.Status(http.StatusBadRequest)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader("email=nope&age=12"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, captured := serve(t, d, engine, req)
+	if captured == nil {
+		t.Fatal("request wasn't captured")
+	}
+
+	got := captured.ValidationErrors
+	if len(got) != 2 {
+		t.Fatalf("ValidationErrors = %+v, want one per field", got)
+	}
+	if got[0].Field != "Email" || got[0].Tag != "email" || got[0].Value != "nope" {
+		t.Errorf("first error = %+v, want the email rule", got[0])
+	}
+	if got[1].Field != "Age" || got[1].Tag != "gte" || got[1].Param != "18" {
+		t.Errorf("second error = %+v, want the age rule", got[1])
+	}
+}
+
+func TestCollectorFunc(t *testing.T) {
+	config := testConfig()
+	config.Collectors = []Collector{CollectorFunc(func(c *gin.Context, req *RequestInfo) {
+		req.ContextKeys = map[string]any{"tenant": c.GetHeader("X-Tenant")}
+	})}
+	d := New(config)
+	engine := testEngine(t, d)
+	engine.GET("/", func(c *gin.Context) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant", "acme")
+	if _, captured := serve(t, d, engine, req); captured == nil || captured.ContextKeys["tenant"] != "acme" {
+		t.Errorf("captured %+v, want the custom collector's value", captured)
+	}
+}
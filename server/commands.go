@@ -0,0 +1,98 @@
+package godebugbar
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CommandError reports a WebSocket command that failed
+type CommandError struct {
+	ID      string `json:"id,omitempty"`
+	Command string `json:"command"`
+	Error   string `json:"error"`
+}
+
+// searchCommand is the payload of a search command
+type searchCommand struct {
+	ID     string `json:"id,omitempty"`
+	Query  string `json:"query"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+// annotateCommand is the payload of the pin, add_note, remove_note and
+// set_labels commands
+type annotateCommand struct {
+	ID        string   `json:"id,omitempty"`
+	RequestID string   `json:"request_id"`
+	Pinned    bool     `json:"pinned"`
+	Text      string   `json:"text"`
+	Author    string   `json:"author"`
+	NoteID    string   `json:"note_id"`
+	Labels    []string `json:"labels"`
+}
+
+// searchResponse answers a search command, echoing its ID
+type searchResponse struct {
+	ID string `json:"id,omitempty"`
+	*SearchResult
+}
+
+// handleCommand runs a command sent by a WebSocket client and replies to
+// that client only
+func (d *DebugBar) handleCommand(c *WebSocketClient, msg clientMessage) {
+	switch msg.Type {
+	case MessageTypeSearch:
+		var cmd searchCommand
+		if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
+			c.reply(commandError(msg.Type, "", err))
+			return
+		}
+		result, err := d.Search(cmd.Query, SearchPage{Offset: cmd.Offset, Limit: cmd.Limit})
+		if err != nil {
+			c.reply(commandError(msg.Type, cmd.ID, err))
+			return
+		}
+		c.reply(WebSocketMessage{
+			Type:    MessageTypeSearchResults,
+			Payload: searchResponse{ID: cmd.ID, SearchResult: result},
+		})
+
+	case MessageTypePin, MessageTypeAddNote, MessageTypeRemoveNote, MessageTypeSetLabels:
+		// Success is broadcast to every client as an annotation message
+		var cmd annotateCommand
+		if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
+			c.reply(commandError(msg.Type, "", err))
+			return
+		}
+		if err := d.runAnnotateCommand(msg.Type, cmd); err != nil {
+			c.reply(commandError(msg.Type, cmd.ID, err))
+		}
+
+	default:
+		c.reply(commandError(msg.Type, "", fmt.Errorf("unknown command %q", msg.Type)))
+	}
+}
+
+// runAnnotateCommand changes the annotation of a request
+func (d *DebugBar) runAnnotateCommand(command string, cmd annotateCommand) error {
+	switch command {
+	case MessageTypePin:
+		return d.PinRequest(cmd.RequestID, cmd.Pinned)
+	case MessageTypeAddNote:
+		_, err := d.AddNote(cmd.RequestID, cmd.Text, cmd.Author)
+		return err
+	case MessageTypeRemoveNote:
+		return d.RemoveNote(cmd.RequestID, cmd.NoteID)
+	default:
+		return d.SetLabels(cmd.RequestID, cmd.Labels)
+	}
+}
+
+// commandError builds the reply to a failed command
+func commandError(command, id string, err error) WebSocketMessage {
+	return WebSocketMessage{
+		Type:    MessageTypeCommandError,
+		Payload: CommandError{ID: id, Command: command, Error: err.Error()},
+	}
+}
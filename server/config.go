@@ -0,0 +1,321 @@
+package godebugbar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// EnvPrefix is the prefix of the environment variables read by LoadConfig
+const EnvPrefix = "DEBUGBAR_"
+
+// ConfigError describes an invalid configuration field
+type ConfigError struct {
+	Field   string
+	Message string
+}
+
+// Error implements error
+func (e ConfigError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ConfigErrors lists every invalid field found when validating a Config
+type ConfigErrors []ConfigError
+
+// Error implements error
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid debug bar config: " + strings.Join(messages, "; ")
+}
+
+// Validate checks the configuration and reports every invalid field as a
+// ConfigErrors value
+func (c Config) Validate() error {
+	var errs ConfigErrors
+	add := func(field, format string, args ...any) {
+		errs = append(errs, ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.MaxRequests <= 0 {
+		add("MaxRequests", "must be greater than 0, got %d", c.MaxRequests)
+	}
+	if !strings.HasPrefix(c.WebSocketPath, "/") {
+		add("WebSocketPath", "must start with /, got %q", c.WebSocketPath)
+	}
+	if c.APIPath != "" && !strings.HasPrefix(c.APIPath, "/") {
+		add("APIPath", "must start with /, got %q", c.APIPath)
+	}
+	if c.MaxBodySize < 0 {
+		add("MaxBodySize", "must not be negative, got %d", c.MaxBodySize)
+	}
+	if c.MaxResponseBodySize < 0 {
+		add("MaxResponseBodySize", "must not be negative, got %d", c.MaxResponseBodySize)
+	}
+	if c.BinaryPreview != "" && c.BinaryPreview != BodyFormatHex && c.BinaryPreview != BodyFormatBase64 {
+		add("BinaryPreview", "must be %q or %q, got %q", BodyFormatHex, BodyFormatBase64, c.BinaryPreview)
+	}
+	if r := c.Retention; r != nil {
+		if r.MaxBytes < 0 {
+			add("Retention.MaxBytes", "must not be negative, got %d", r.MaxBytes)
+		}
+		if r.MaxAge < 0 {
+			add("Retention.MaxAge", "must not be negative, got %s", r.MaxAge)
+		}
+		if r.NotableMaxAge < 0 {
+			add("Retention.NotableMaxAge", "must not be negative, got %s", r.NotableMaxAge)
+		}
+	}
+	for i, rule := range c.CaptureRules {
+		if rule.SampleRate < 0 || rule.SampleRate > 1 {
+			add(fmt.Sprintf("CaptureRules[%d].SampleRate", i), "must be between 0 and 1, got %g", rule.SampleRate)
+		}
+	}
+	if c.Activation != nil && len(c.Activation.Secret) == 0 {
+		add("Activation.Secret", "is required when activation is enabled")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// fileConfig is the subset of Config that can be set from a file or the
+// environment. Nil fields leave the existing value unchanged.
+type fileConfig struct {
+	Enabled             *bool    `json:"enabled" yaml:"enabled" toml:"enabled"`
+	WebSocketPath       *string  `json:"websocket_path" yaml:"websocket_path" toml:"websocket_path"`
+	APIPath             *string  `json:"api_path" yaml:"api_path" toml:"api_path"`
+	MaxRequests         *int     `json:"max_requests" yaml:"max_requests" toml:"max_requests"`
+	CaptureRequestBody  *bool    `json:"capture_request_body" yaml:"capture_request_body" toml:"capture_request_body"`
+	MaxBodySize         *int     `json:"max_body_size" yaml:"max_body_size" toml:"max_body_size"`
+	CaptureResponseBody *bool    `json:"capture_response_body" yaml:"capture_response_body" toml:"capture_response_body"`
+	MaxResponseBodySize *int     `json:"max_response_body_size" yaml:"max_response_body_size" toml:"max_response_body_size"`
+	BinaryPreview       *string  `json:"binary_preview" yaml:"binary_preview" toml:"binary_preview"`
+	AllowedOrigins      []string `json:"allowed_origins" yaml:"allowed_origins" toml:"allowed_origins"`
+	MultiValueOnly      *bool    `json:"multi_value_only" yaml:"multi_value_only" toml:"multi_value_only"`
+	ReadOnly            *bool    `json:"read_only" yaml:"read_only" toml:"read_only"`
+	ActivationSecret    *string  `json:"activation_secret" yaml:"activation_secret" toml:"activation_secret"`
+	AuthToken           *string  `json:"auth_token" yaml:"auth_token" toml:"auth_token"`
+	AuthAllowIPs        []string `json:"auth_allow_ips" yaml:"auth_allow_ips" toml:"auth_allow_ips"`
+}
+
+// LoadConfig builds a Config from DefaultConfig(), overridden by the file
+// at path, if path isn't empty, and then by DEBUGBAR_* environment
+// variables. The file format is chosen by extension: .json, .yaml, .yml or
+// .toml. When path is empty, DEBUGBAR_CONFIG may name the file instead.
+// The result is validated.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	settings := &fileConfig{}
+
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if path != "" {
+		file, err := readConfigFile(path)
+		if err != nil {
+			return config, err
+		}
+		settings.merge(file)
+	}
+
+	env, err := readConfigEnv()
+	if err != nil {
+		return config, err
+	}
+	settings.merge(env)
+
+	if err := settings.apply(&config); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+// readConfigFile decodes a JSON, YAML or TOML configuration file
+func readConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading debug bar config: %w", err)
+	}
+
+	file := &fileConfig{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(file)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalWithOptions(data, file, yaml.Strict())
+	case ".toml":
+		err = toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(file)
+	default:
+		return nil, fmt.Errorf("unsupported debug bar config format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing debug bar config %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// readConfigEnv reads the DEBUGBAR_* environment variables. Lists are
+// comma separated.
+func readConfigEnv() (*fileConfig, error) {
+	env := &fileConfig{}
+	var errs ConfigErrors
+
+	lookup := func(name string) (string, bool) {
+		value, ok := os.LookupEnv(EnvPrefix + name)
+		return strings.TrimSpace(value), ok
+	}
+	setString := func(name string, target **string) {
+		if value, ok := lookup(name); ok {
+			*target = &value
+		}
+	}
+	setBool := func(name string, target **bool) {
+		if value, ok := lookup(name); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, ConfigError{Field: EnvPrefix + name, Message: fmt.Sprintf("invalid boolean %q", value)})
+				return
+			}
+			*target = &b
+		}
+	}
+	setInt := func(name string, target **int) {
+		if value, ok := lookup(name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, ConfigError{Field: EnvPrefix + name, Message: fmt.Sprintf("invalid integer %q", value)})
+				return
+			}
+			*target = &n
+		}
+	}
+	setList := func(name string, target *[]string) {
+		if value, ok := lookup(name); ok {
+			*target = splitList(value)
+		}
+	}
+
+	setBool("ENABLED", &env.Enabled)
+	setString("WEBSOCKET_PATH", &env.WebSocketPath)
+	setString("API_PATH", &env.APIPath)
+	setInt("MAX_REQUESTS", &env.MaxRequests)
+	setBool("CAPTURE_REQUEST_BODY", &env.CaptureRequestBody)
+	setInt("MAX_BODY_SIZE", &env.MaxBodySize)
+	setBool("CAPTURE_RESPONSE_BODY", &env.CaptureResponseBody)
+	setInt("MAX_RESPONSE_BODY_SIZE", &env.MaxResponseBodySize)
+	setString("BINARY_PREVIEW", &env.BinaryPreview)
+	setList("ALLOWED_ORIGINS", &env.AllowedOrigins)
+	setBool("MULTI_VALUE_ONLY", &env.MultiValueOnly)
+	setBool("READ_ONLY", &env.ReadOnly)
+	setString("ACTIVATION_SECRET", &env.ActivationSecret)
+	setString("AUTH_TOKEN", &env.AuthToken)
+	setList("AUTH_ALLOW_IPS", &env.AuthAllowIPs)
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return env, nil
+}
+
+// merge overrides f with the fields that are set in other
+func (f *fileConfig) merge(other *fileConfig) {
+	target := reflect.ValueOf(f).Elem()
+	source := reflect.ValueOf(other).Elem()
+	for i := 0; i < source.NumField(); i++ {
+		if !source.Field(i).IsNil() {
+			target.Field(i).Set(source.Field(i))
+		}
+	}
+}
+
+// apply copies the fields that are set onto config
+func (f *fileConfig) apply(config *Config) error {
+	setBool(&config.Enabled, f.Enabled)
+	setString(&config.WebSocketPath, f.WebSocketPath)
+	setString(&config.APIPath, f.APIPath)
+	setInt(&config.MaxRequests, f.MaxRequests)
+	setBool(&config.CaptureRequestBody, f.CaptureRequestBody)
+	setInt(&config.MaxBodySize, f.MaxBodySize)
+	setBool(&config.CaptureResponseBody, f.CaptureResponseBody)
+	setInt(&config.MaxResponseBodySize, f.MaxResponseBodySize)
+	setString(&config.BinaryPreview, f.BinaryPreview)
+	setBool(&config.MultiValueOnly, f.MultiValueOnly)
+	setBool(&config.ReadOnly, f.ReadOnly)
+	if f.AllowedOrigins != nil {
+		config.AllowedOrigins = f.AllowedOrigins
+	}
+
+	if f.ActivationSecret != nil {
+		config.Activation = nil
+		if *f.ActivationSecret != "" {
+			config.Activation = &ActivationConfig{Secret: []byte(*f.ActivationSecret)}
+		}
+	}
+
+	// When both are set a client must pass the allowlist and the token.
+	// Empty values count as unset, so a blank variable can't lock everyone out
+	var auths []Authenticator
+	if len(f.AuthAllowIPs) > 0 {
+		allowlist, err := IPAllowlist(f.AuthAllowIPs...)
+		if err != nil {
+			return ConfigErrors{{Field: "auth_allow_ips", Message: err.Error()}}
+		}
+		auths = append(auths, allowlist)
+	}
+	if f.AuthToken != nil && *f.AuthToken != "" {
+		auths = append(auths, TokenAuth(*f.AuthToken))
+	}
+	switch len(auths) {
+	case 1:
+		config.Auth = auths[0]
+	case 2:
+		config.Auth = AllOf(auths...)
+	}
+
+	return nil
+}
+
+func setBool(target *bool, value *bool) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func setString(target *string, value *string) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func setInt(target *int, value *int) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
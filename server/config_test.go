@@ -0,0 +1,167 @@
+package godebugbar
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig writes a config file into a temporary directory
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"debugbar.json": `{"max_requests": 50, "websocket_path": "/ws", "capture_response_body": false, "allowed_origins": ["https://a.test"]}`,
+		"debugbar.yaml": "max_requests: 50\nwebsocket_path: /ws\ncapture_response_body: false\nallowed_origins: [https://a.test]\n",
+		"debugbar.yml":  "max_requests: 50\nwebsocket_path: /ws\ncapture_response_body: false\nallowed_origins: [https://a.test]\n",
+		"debugbar.toml": "max_requests = 50\nwebsocket_path = \"/ws\"\ncapture_response_body = false\nallowed_origins = [\"https://a.test\"]\n",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadConfig(writeConfig(t, name, content))
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if config.MaxRequests != 50 || config.WebSocketPath != "/ws" || config.CaptureResponseBody {
+				t.Errorf("config = %+v, want the file's settings", config)
+			}
+			if len(config.AllowedOrigins) != 1 || config.AllowedOrigins[0] != "https://a.test" {
+				t.Errorf("AllowedOrigins = %v", config.AllowedOrigins)
+			}
+			// Unset keys keep their defaults
+			if config.APIPath != DefaultConfig().APIPath || !config.CaptureRequestBody {
+				t.Errorf("defaults were overwritten: %+v", config)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"unknown json key", "c.json", `{"max_request": 5}`},
+		{"unknown yaml key", "c.yaml", "max_request: 5\n"},
+		{"unknown toml key", "c.toml", "max_request = 5\n"},
+		{"unsupported format", "c.ini", "max_requests=5"},
+		{"malformed json", "c.json", `{"max_requests": `},
+		{"wrong type", "c.yaml", "max_requests: lots\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadConfig(writeConfig(t, tt.file, tt.content)); err == nil {
+				t.Error("LoadConfig() succeeded")
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadConfig() succeeded for a missing file")
+	}
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	path := writeConfig(t, "debugbar.yaml", "max_requests: 50\nread_only: false\n")
+	t.Setenv("DEBUGBAR_CONFIG", path)
+	t.Setenv("DEBUGBAR_MAX_REQUESTS", " 75 ")
+	t.Setenv("DEBUGBAR_READ_ONLY", "true")
+	t.Setenv("DEBUGBAR_ALLOWED_ORIGINS", "https://a.test, ,https://b.test")
+	t.Setenv("DEBUGBAR_ACTIVATION_SECRET", "s3cret")
+
+	config, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.MaxRequests != 75 || !config.ReadOnly {
+		t.Errorf("config = %+v, want the environment to override the file", config)
+	}
+	if len(config.AllowedOrigins) != 2 || config.AllowedOrigins[1] != "https://b.test" {
+		t.Errorf("AllowedOrigins = %q", config.AllowedOrigins)
+	}
+	if config.Activation == nil || string(config.Activation.Secret) != "s3cret" {
+		t.Errorf("Activation = %+v, want the secret", config.Activation)
+	}
+
+	t.Setenv("DEBUGBAR_ENABLED", "maybe")
+	t.Setenv("DEBUGBAR_MAX_BODY_SIZE", "big")
+	_, err = LoadConfig("")
+	var errs ConfigErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("LoadConfig() error = %v, want both bad variables reported", err)
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	t.Setenv("DEBUGBAR_MAX_REQUESTS", "0")
+	t.Setenv("DEBUGBAR_WEBSOCKET_PATH", "ws")
+	_, err := LoadConfig("")
+	var errs ConfigErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Field != "MaxRequests" || errs[1].Field != "WebSocketPath" {
+		t.Errorf("LoadConfig() error = %v, want MaxRequests and WebSocketPath errors", err)
+	}
+}
+
+func TestLoadConfigAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		allowIPs string
+		remote   string
+		bearer   string
+		allowed  bool
+	}{
+		{"token", "s3cret", "", "8.8.8.8:1", "s3cret", true},
+		{"wrong token", "s3cret", "", "8.8.8.8:1", "nope", false},
+		{"allowlist", "", "10.0.0.0/8", "10.1.1.1:1", "", true},
+		{"outside the allowlist", "", "10.0.0.0/8", "8.8.8.8:1", "", false},
+		{"both need the token", "s3cret", "10.0.0.0/8", "10.1.1.1:1", "", false},
+		{"both", "s3cret", "10.0.0.0/8", "10.1.1.1:1", "s3cret", true},
+		// Empty values leave the default localhost-only auth in place
+		{"empty allowlist", "", " ", "127.0.0.1:1", "", true},
+		{"empty allowlist, remote", "", "", "8.8.8.8:1", "", false},
+		{"empty token", "", "", "127.0.0.1:1", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEBUGBAR_AUTH_TOKEN", tt.token)
+			t.Setenv("DEBUGBAR_AUTH_ALLOW_IPS", tt.allowIPs)
+			config, err := LoadConfig("")
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			auth := config.Auth
+			if auth == nil {
+				auth = LocalhostOnly()
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if err := auth.Authenticate(req); (err == nil) != tt.allowed {
+				t.Errorf("Authenticate() error = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+
+	t.Setenv("DEBUGBAR_AUTH_ALLOW_IPS", "10.0.0.0/99")
+	if _, err := LoadConfig(""); err == nil {
+		t.Error("LoadConfig() accepted an invalid allowlist")
+	}
+}
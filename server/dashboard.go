@@ -0,0 +1,34 @@
+package godebugbar
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+// dashboardTemplate renders the dashboard page with the endpoint paths
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// dashboardData holds the values the dashboard page needs
+type dashboardData struct {
+	WebSocketPath string
+	APIPath       string
+}
+
+// serveDashboard serves a page listing captured requests as they arrive,
+// with the details of a request shown when it is selected
+func (d *DebugBar) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy",
+		"default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self' ws: wss:")
+	data := dashboardData{
+		WebSocketPath: d.config.WebSocketPath,
+		APIPath:       d.config.APIPath,
+	}
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		d.logger.Printf("Debug bar dashboard error: %v", err)
+	}
+}
@@ -2,8 +2,14 @@ package godebugbar
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net/http"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -21,21 +27,121 @@ const (
 // DebugBar is the main debug bar instance
 type DebugBar struct {
 	config    Config
-	store     *RequestStore
+	store     Store
 	wsHub     *WebSocketHub
+	lintRules []LintRule
+	encoder   *ValueEncoder
+	redactor  *Redactor
+	auth      Authenticator
+	rejected  atomic.Int64
+	server    *http.Server
+	clock     func() time.Time
+	logger    Logger
 	mu        sync.RWMutex
+
+	bodyDecoders map[string]BodyDecoder
+	collectors   []Collector
+	captureRules []captureRule
+	retention    *retention
+	storeMu      sync.Mutex
+
+	// stop is closed by Close to end the retention sweeper
+	stop      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
 }
 
-// New creates a new DebugBar instance with the given configuration
+// New creates a new DebugBar instance with the given configuration. Use
+// NewWithOptions to have the configuration validated.
 func New(config Config) *DebugBar {
+	return newDebugBar(&options{config: config})
+}
+
+// newDebugBar creates a DebugBar from validated or legacy options
+func newDebugBar(o *options) *DebugBar {
+	config := o.config
+	if config.APIPath == "" {
+		config.APIPath = DefaultAPIPath
+	}
+
 	db := &DebugBar{
-		config: config,
-		store:  NewRequestStore(config.MaxRequests),
-		wsHub:  NewWebSocketHub(),
+		config:       config,
+		store:        o.store,
+		wsHub:        NewWebSocketHub(),
+		lintRules:    append([]LintRule(nil), config.LintRules...),
+		collectors:   append([]Collector(nil), config.Collectors...),
+		captureRules: compileCaptureRules(config.CaptureRules),
+		encoder:      config.ValueEncoder,
+		redactor:     o.redactor,
+		auth:         config.Auth,
+		clock:        o.clock,
+		logger:       o.logger,
+		stop:         make(chan struct{}),
+	}
+
+	if db.store == nil {
+		db.store = NewRequestStore(config.MaxRequests)
+	}
+
+	policy := RetentionPolicy{}
+	if config.Retention != nil {
+		policy = *config.Retention
+	}
+	db.retention = newRetention(policy, config.MaxRequests)
+	if db.encoder == nil {
+		db.encoder = DefaultValueEncoder()
+	}
+	if db.auth == nil {
+		db.auth = LocalhostOnly()
+	}
+	if db.clock == nil {
+		db.clock = time.Now
+	}
+	if db.logger == nil {
+		db.logger = defaultLogger
+	}
+	db.wsHub.logger = db.logger
+
+	if db.redactor == nil {
+		redaction := DefaultRedactionConfig()
+		if config.Redaction != nil {
+			redaction = *config.Redaction
+		}
+		if config.Activation != nil {
+			// Keep activation tokens out of captured headers, query strings
+			// and cookies
+			activation := config.Activation.withDefaults()
+			redaction.Headers = append(append([]string(nil), redaction.Headers...), activation.HeaderName)
+			redaction.QueryParams = append(append([]string(nil), redaction.QueryParams...), activation.QueryParam)
+			if !masksAllCookies(redaction) {
+				redaction.Cookies = append(append([]string(nil), redaction.Cookies...), activation.CookieName)
+			}
+		}
+		db.redactor = NewRedactor(redaction)
+	}
+
+	db.bodyDecoders = make(map[string]BodyDecoder, len(config.BodyDecoders))
+	for mediaType, decoder := range config.BodyDecoders {
+		db.bodyDecoders[strings.ToLower(mediaType)] = decoder
+	}
+
+	// Account for history already in a persistent store, aging imported
+	// requests from when they were imported like Import does
+	for _, req := range db.listHistory(StoreFilter{}) {
+		added := req.StartTime
+		if req.ImportedAt != nil {
+			added = *req.ImportedAt
+		}
+		for _, eviction := range db.retention.admit(req, db.retention.sizeOf(req), added, db.clock()) {
+			db.deleteFromStore(eviction.IDs)
+		}
 	}
 
 	if config.Enabled {
 		go db.wsHub.Run()
+		if interval := db.retention.sweepInterval(); interval > 0 {
+			go db.sweepHistory(interval)
+		}
 	}
 
 	return db
@@ -46,6 +152,22 @@ func NewWithDefaults() *DebugBar {
 	return New(DefaultConfig())
 }
 
+// Close stops the debug bar's background goroutines, disconnecting
+// WebSocket clients, and closes the store if it has a Close method, such as
+// a GormStore, returning its error. Call it once the application has stopped
+// serving requests; a server started with ListenAndServe is stopped with
+// Shutdown. Closing again does nothing.
+func (d *DebugBar) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.stop)
+		d.wsHub.Stop()
+		if closer, ok := d.store.(io.Closer); ok {
+			d.closeErr = closer.Close()
+		}
+	})
+	return d.closeErr
+}
+
 // Middleware returns the Gin middleware for request tracking
 func (d *DebugBar) Middleware() gin.HandlerFunc {
 	return d.ginMiddleware()
@@ -75,9 +197,9 @@ func (d *DebugBar) AddError(c *gin.Context, err error, errType string, ctx map[s
 	errorInfo := ErrorInfo{
 		ID:        uuid.New().String(),
 		RequestID: reqInfo.ID,
-		Message:   err.Error(),
+		Message:   d.redactor.RedactString(err.Error()),
 		Type:      errType,
-		Context:   ctx,
+		Context:   d.redactor.RedactValues(d.encoder.EncodeMap(ctx)),
 	}
 
 	// Capture stack trace
@@ -107,18 +229,15 @@ func (d *DebugBar) AddCustomData(c *gin.Context, key string, value any) {
 	if reqInfo.CustomData == nil {
 		reqInfo.CustomData = make(map[string]any)
 	}
-	reqInfo.CustomData[key] = value
+	// Redact under the key so a sensitive key name masks the whole value
+	encoded := d.redactor.RedactValues(map[string]any{key: d.encoder.Encode(value)})
+	reqInfo.CustomData[key] = encoded[key]
 	d.mu.Unlock()
 }
 
 // GetRequestInfo retrieves the request info from the Gin context
 func (d *DebugBar) GetRequestInfo(c *gin.Context) *RequestInfo {
-	if val, exists := c.Get(string(DebugBarContextKey)); exists {
-		if reqInfo, ok := val.(*RequestInfo); ok {
-			return reqInfo
-		}
-	}
-	return nil
+	return requestInfoFromGin(c)
 }
 
 // GetRequestInfoFromContext retrieves the request info from a standard context
@@ -133,17 +252,64 @@ func (d *DebugBar) GetRequestInfoFromContext(ctx context.Context) *RequestInfo {
 
 // GetHistory returns the request history
 func (d *DebugBar) GetHistory() []*RequestInfo {
-	return d.store.GetAll()
+	return d.listHistory(StoreFilter{})
 }
 
 // GetRecentHistory returns the most recent n requests
 func (d *DebugBar) GetRecentHistory(n int) []*RequestInfo {
-	return d.store.GetRecent(n)
+	if n <= 0 {
+		return make([]*RequestInfo, 0)
+	}
+	return d.listHistory(StoreFilter{Limit: n})
+}
+
+// GetHistoryByUser returns the stored requests made by a user. The query
+// matches a user ID exactly or is a case-insensitive substring of the name.
+func (d *DebugBar) GetHistoryByUser(query string) []*RequestInfo {
+	return d.listHistory(StoreFilter{Match: func(req *RequestInfo) bool {
+		return matchUser(req, query)
+	}})
+}
+
+// listHistory lists stored requests, logging store failures
+func (d *DebugBar) listHistory(filter StoreFilter) []*RequestInfo {
+	requests, err := d.store.List(filter)
+	if err != nil {
+		d.logger.Printf("Debug bar store error: %v", err)
+		return make([]*RequestInfo, 0)
+	}
+	return requests
 }
 
 // ClearHistory clears all stored requests
 func (d *DebugBar) ClearHistory() {
-	d.store.Clear()
+	if err := d.clearHistory(); err != nil {
+		d.logger.Printf("Debug bar store error: %v", err)
+	}
+}
+
+// clearHistory clears the store and tells clients the requests are gone
+func (d *DebugBar) clearHistory() error {
+	if d.config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	d.storeMu.Lock()
+	ids := d.retention.ids()
+	err := d.store.Clear()
+	d.retention.reset()
+	d.storeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(ids) > 0 {
+		d.broadcast(WebSocketMessage{
+			Type:    MessageTypeEvicted,
+			Payload: EvictionInfo{IDs: ids, Reason: EvictReasonClear},
+		})
+	}
+	return nil
 }
 
 // IsEnabled returns whether the debug bar is enabled
@@ -173,19 +339,138 @@ func (d *DebugBar) addQuery(ctx context.Context, query QueryInfo) {
 	}
 
 	query.RequestID = reqInfo.ID
+	query.Args = d.redactor.RedactArgs(query.Query, d.encoder.EncodeSlice(query.Args))
+	query.Query = d.redactor.RedactString(query.Query)
+	query.shape = normalizeSQL(query.Query)
 
 	d.mu.Lock()
 	reqInfo.Queries = append(reqInfo.Queries, query)
+	if reqInfo.shapeCounts == nil {
+		reqInfo.shapeCounts = make(map[string]int)
+	}
+	reqInfo.shapeCounts[query.shape]++
+	// Lint a snapshot so rules run without holding the lock. Appends to
+	// the request never touch the elements the snapshot can see.
+	snapshot := *reqInfo
+	snapshot.shapeCounts = map[string]int{query.shape: reqInfo.shapeCounts[query.shape]}
+	rules := d.lintRules
 	d.mu.Unlock()
 
+	warnings := d.lintQuery(rules, query, &snapshot)
+	if len(warnings) > 0 {
+		d.mu.Lock()
+		reqInfo.Errors = append(reqInfo.Errors, warnings...)
+		d.mu.Unlock()
+	}
+
 	// Broadcast query to WebSocket clients
 	d.broadcast(WebSocketMessage{
 		Type:    MessageTypeQuery,
 		Payload: query,
 	})
+
+	// Broadcast any lint warnings raised by the query
+	for _, warning := range warnings {
+		d.broadcast(WebSocketMessage{
+			Type:    MessageTypeError,
+			Payload: warning,
+		})
+	}
 }
 
-// storeRequest stores a completed request
+// storeRequest stores a completed request, first evicting the requests
+// the retention policy drops to make room for it
 func (d *DebugBar) storeRequest(req *RequestInfo) {
-	d.store.Add(req)
+	d.mu.RLock()
+	size := d.retention.sizeOf(req)
+	d.mu.RUnlock()
+
+	if err := d.saveRequest(req, size, req.StartTime); err != nil {
+		d.logger.Printf("Debug bar store error: %v", err)
+	}
+}
+
+// saveRequest adds a request to the store, with retention measuring its
+// age from added, and broadcasts the evictions it causes
+func (d *DebugBar) saveRequest(req *RequestInfo, size int64, added time.Time) error {
+	d.storeMu.Lock()
+	evictions := d.retention.admit(req, size, added, d.clock())
+	for _, eviction := range evictions {
+		d.deleteFromStore(eviction.IDs)
+	}
+	err := d.store.Add(req)
+	if err != nil {
+		d.retention.remove(req.ID)
+	}
+	d.storeMu.Unlock()
+
+	for _, eviction := range evictions {
+		d.broadcast(WebSocketMessage{
+			Type:    MessageTypeEvicted,
+			Payload: eviction,
+		})
+	}
+	return err
+}
+
+// sweepHistory expires requests past their max age every interval, so they
+// are dropped even when no new requests arrive
+func (d *DebugBar) sweepHistory(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.expireHistory()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// expireHistory removes the requests retention has expired from the store
+// and tells clients
+func (d *DebugBar) expireHistory() {
+	d.storeMu.Lock()
+	expired := d.retention.sweep(d.clock())
+	d.deleteFromStore(expired)
+	d.storeMu.Unlock()
+
+	if len(expired) > 0 {
+		d.broadcast(WebSocketMessage{
+			Type:    MessageTypeEvicted,
+			Payload: EvictionInfo{IDs: expired, Reason: EvictReasonAge},
+		})
+	}
+}
+
+// deleteFromStore removes evicted requests from the store
+func (d *DebugBar) deleteFromStore(ids []string) {
+	for _, id := range ids {
+		if err := d.store.Delete(id); err != nil && !errors.Is(err, ErrRequestNotFound) {
+			d.logger.Printf("Debug bar store error: %v", err)
+		}
+	}
+}
+
+// DeleteRequest removes a request from the history
+func (d *DebugBar) DeleteRequest(id string) error {
+	if d.config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	d.storeMu.Lock()
+	err := d.store.Delete(id)
+	d.retention.remove(id)
+	d.storeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	d.broadcast(WebSocketMessage{
+		Type:    MessageTypeEvicted,
+		Payload: EvictionInfo{IDs: []string{id}, Reason: EvictReasonDelete},
+	})
+	return nil
 }
@@ -0,0 +1,169 @@
+package godebugbar
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestUserResolver(t *testing.T) {
+	config := testConfig()
+	redaction := DefaultRedactionConfig()
+	config.Redaction = &redaction
+	config.UserResolver = func(c *gin.Context) *UserInfo {
+		id := c.GetString("user_id")
+		if id == "" {
+			return nil
+		}
+		return &UserInfo{
+			ID:         id,
+			Name:       "Ada Lovelace",
+			Roles:      []string{"admin"},
+			Attributes: map[string]any{"plan": "pro", "api_key": "sk-live-123"},
+		}
+	}
+	d := New(config)
+	engine := testEngine(t, d)
+	engine.GET("/me", func(c *gin.Context) {
+		// The resolver runs after auth middleware has set the user
+		if c.Query("as") != "" {
+			c.Set("user_id", c.Query("as"))
+		}
+	})
+
+	_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodGet, "/me?as=u1", nil))
+	if captured == nil || captured.User == nil {
+		t.Fatalf("captured %+v, want a user", captured)
+	}
+	if captured.User.ID != "u1" || captured.User.Name != "Ada Lovelace" || len(captured.User.Roles) != 1 {
+		t.Errorf("User = %+v", captured.User)
+	}
+	if captured.User.Attributes["plan"] != "pro" || captured.User.Attributes["api_key"] != DefaultRedactionReplacement {
+		t.Errorf("Attributes = %v, want the API key redacted", captured.User.Attributes)
+	}
+
+	_, anonymous := serve(t, d, engine, httptest.NewRequest(http.MethodGet, "/me", nil))
+	if anonymous == nil || anonymous.User != nil {
+		t.Errorf("anonymous request has user %+v", anonymous)
+	}
+}
+
+func TestGetHistoryByUser(t *testing.T) {
+	d := New(testConfig())
+	requests := []*RequestInfo{
+		{ID: "1", User: &UserInfo{ID: "42", Name: "Ada Lovelace"}},
+		{ID: "2", User: &UserInfo{ID: "420", Name: "Grace Hopper"}},
+		{ID: "3"},
+		{ID: "4", User: &UserInfo{ID: "7"}},
+	}
+	for _, req := range requests {
+		d.store.Add(req)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"42", []string{"1"}},
+		{"lovelace", []string{"1"}},
+		{"HOPPER", []string{"2"}},
+		{"a", []string{"1", "2"}},
+		{"7", []string{"4"}},
+		{"nobody", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var got []string
+			for _, req := range d.GetHistoryByUser(tt.query) {
+				got = append(got, req.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetHistoryByUser(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("GetHistoryByUser(%q) = %v, want %v", tt.query, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	store := openGormStore(t, filepath.Join(t.TempDir(), "debugbar.db"))
+	config := testConfig()
+	config.Retention = &RetentionPolicy{MaxAge: time.Hour}
+	d, err := NewWithOptions(WithConfig(config), WithStore(store), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := testEngine(t, d)
+	d.RegisterRoutes(engine)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + d.config.WebSocketPath
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Connected clients are disconnected and new ones turned away
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived) {
+		t.Errorf("WebSocket read error = %v, want a close", err)
+	}
+	if late, _, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+		late.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, _, err := late.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Errorf("client connecting after Close read %v, want a going away close", err)
+		}
+		late.Close()
+	}
+	if d.wsHub.ClientCount() != 0 {
+		t.Errorf("ClientCount() = %d after Close", d.wsHub.ClientCount())
+	}
+
+	// The store it was given is closed too
+	if err := store.Add(&RequestInfo{ID: "late"}); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("store Add() error = %v, want ErrStoreClosed", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+
+	// The hub, sweeper, store writer and client goroutines have all exited
+	conn.Close()
+	server.Close()
+	if sqlDB, err := store.db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines still running after Close, %d before", after, before)
+	}
+}
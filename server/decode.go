@@ -0,0 +1,286 @@
+package godebugbar
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Formats reported for captured request and response bodies
+const (
+	BodyFormatText    = "text"
+	BodyFormatDecoded = "decoded"
+	BodyFormatBase64  = "base64"
+	BodyFormatHex     = "hex"
+)
+
+// binaryPreviewSize is the number of bytes kept in a binary body preview
+const binaryPreviewSize = 1024
+
+// BodyDecoder renders a body of a particular content type as readable text,
+// for example protobuf messages as JSON
+type BodyDecoder interface {
+	Decode(contentType string, body []byte) (string, error)
+}
+
+// BodyDecoderFunc adapts a function to the BodyDecoder interface
+type BodyDecoderFunc func(contentType string, body []byte) (string, error)
+
+// Decode calls f(contentType, body)
+func (f BodyDecoderFunc) Decode(contentType string, body []byte) (string, error) {
+	return f(contentType, body)
+}
+
+// MessageDecoder is a BodyDecoder for bodies holding a sequence of
+// messages, such as gRPC-web streams. Each message is redacted on its own
+// before they are joined with newlines.
+type MessageDecoder interface {
+	BodyDecoder
+	DecodeMessages(contentType string, body []byte) ([]string, error)
+}
+
+// RegisterBodyDecoder registers a decoder for a media type such as
+// "application/x-protobuf". A "type/*" media type matches every subtype.
+func (d *DebugBar) RegisterBodyDecoder(mediaType string, decoder BodyDecoder) {
+	d.mu.Lock()
+	d.bodyDecoders[strings.ToLower(mediaType)] = decoder
+	d.mu.Unlock()
+}
+
+// bodyDecoder returns the decoder registered for a media type, if any
+func (d *DebugBar) bodyDecoder(mediaType string) BodyDecoder {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if decoder, ok := d.bodyDecoders[mediaType]; ok {
+		return decoder
+	}
+	if major, _, ok := strings.Cut(mediaType, "/"); ok {
+		return d.bodyDecoders[major+"/*"]
+	}
+	return nil
+}
+
+// renderBody converts captured body bytes into a string for display and
+// reports the format of the result. Compressed bodies are decoded, registered
+// decoders are applied, text is kept as text and binary data is previewed
+// using the configured BinaryPreview format.
+func (d *DebugBar) renderBody(data []byte, contentType, contentEncoding string, limit int, truncated bool) (string, string, bool) {
+	if len(data) == 0 {
+		return "", "", truncated
+	}
+
+	if decoded, complete, err := decodeContentEncoding(contentEncoding, data, limit); err == nil {
+		data = decoded
+		truncated = truncated || !complete
+	} else {
+		// Unknown or corrupt encoding, the raw bytes are all we have
+		return d.binaryPreview(data), d.binaryFormat(), truncated
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+
+	if decoder := d.bodyDecoder(mediaType); decoder != nil && !truncated {
+		if text, err := d.decodeBody(decoder, contentType, data); err == nil {
+			return text, BodyFormatDecoded, truncated
+		}
+	}
+
+	if isText(mediaType, data, truncated) {
+		return strings.ToValidUTF8(string(data), "\uFFFD"), BodyFormatText, truncated
+	}
+
+	return d.binaryPreview(data), d.binaryFormat(), truncated || len(data) > binaryPreviewSize
+}
+
+// decodeBody renders a body with a registered decoder. Decoders render
+// structured bodies such as protobuf as JSON, so the output is redacted as
+// JSON whatever the original content type, one message at a time for a
+// MessageDecoder.
+func (d *DebugBar) decodeBody(decoder BodyDecoder, contentType string, data []byte) (string, error) {
+	messages, ok := decoder.(MessageDecoder)
+	if !ok {
+		text, err := decoder.Decode(contentType, data)
+		if err != nil {
+			return "", err
+		}
+		return d.redactor.RedactBody("application/json", text), nil
+	}
+
+	texts, err := messages.DecodeMessages(contentType, data)
+	if err != nil {
+		return "", err
+	}
+	for i, text := range texts {
+		texts[i] = d.redactor.RedactBody("application/json", text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// redactRenderedBody masks sensitive values in a body returned by
+// renderBody. Decoded bodies were already redacted by decodeBody.
+func (d *DebugBar) redactRenderedBody(contentType, format, body string) string {
+	if format == BodyFormatText {
+		return d.redactor.RedactBody(contentType, body)
+	}
+	return body
+}
+
+// formatResponseBody renders and redacts the response body captured by rw
+// and stores it on reqInfo
+func (d *DebugBar) formatResponseBody(rw *responseWriter, reqInfo *RequestInfo) {
+	header := rw.Header()
+	reqInfo.ResponseContentType = header.Get("Content-Type")
+	reqInfo.ResponseBody, reqInfo.ResponseBodyFormat, reqInfo.ResponseBodyTruncated = d.renderBody(
+		rw.body.Bytes(),
+		reqInfo.ResponseContentType,
+		header.Get("Content-Encoding"),
+		rw.limit,
+		rw.truncated,
+	)
+	reqInfo.ResponseBody = d.redactRenderedBody(reqInfo.ResponseContentType, reqInfo.ResponseBodyFormat, reqInfo.ResponseBody)
+}
+
+// binaryFormat returns the configured preview format for binary bodies
+func (d *DebugBar) binaryFormat() string {
+	if d.config.BinaryPreview == BodyFormatBase64 {
+		return BodyFormatBase64
+	}
+	return BodyFormatHex
+}
+
+// binaryPreview renders the start of a binary body as base64 or a hex dump
+func (d *DebugBar) binaryPreview(data []byte) string {
+	if len(data) > binaryPreviewSize {
+		data = data[:binaryPreviewSize]
+	}
+	if d.binaryFormat() == BodyFormatBase64 {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	return hex.Dump(data)
+}
+
+// decodeContentEncoding decompresses data encoded with the given HTTP
+// content codings, keeping at most limit bytes of output. It reports whether
+// the full decoded body fit within the limit.
+func decodeContentEncoding(contentEncoding string, data []byte, limit int) ([]byte, bool, error) {
+	encodings := strings.Split(strings.ToLower(contentEncoding), ",")
+	complete := true
+
+	// Codings are listed in the order they were applied, so undo them in reverse
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.TrimSpace(encodings[i])
+		if encoding == "" || encoding == "identity" {
+			continue
+		}
+
+		reader, err := contentDecoder(encoding, data)
+		if err != nil {
+			return nil, false, err
+		}
+
+		decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+		if closer, ok := reader.(io.Closer); ok {
+			closer.Close()
+		}
+		if err != nil {
+			// A truncated capture ends mid-stream, keep what was decoded
+			if len(decoded) == 0 && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, false, err
+			}
+			complete = false
+		}
+		if len(decoded) > limit {
+			decoded = decoded[:limit]
+			complete = false
+		}
+		data = decoded
+	}
+
+	return data, complete, nil
+}
+
+// contentDecoder returns a reader that decodes a single content coding
+func contentDecoder(encoding string, data []byte) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(bytes.NewReader(data))
+	case "deflate":
+		// HTTP deflate is zlib-wrapped, but some servers send raw deflate
+		if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+			return zr, nil
+		}
+		return flate.NewReader(bytes.NewReader(data)), nil
+	case "br":
+		return brotli.NewReader(bytes.NewReader(data)), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+// isText reports whether a body should be displayed as text. Declared text
+// types must hold valid UTF-8, anything else is checked by content.
+func isText(mediaType string, data []byte, truncated bool) bool {
+	if truncated {
+		// Ignore a multi-byte rune cut off at the end of the capture
+		for i := 1; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+
+	if !utf8.Valid(data) {
+		return false
+	}
+	if isTextContentType(mediaType) {
+		return true
+	}
+
+	// Treat unknown types as text when they have no control characters
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			return false
+		}
+	}
+	return mediaType == "" || mediaType == "application/octet-stream" || strings.HasPrefix(mediaType, "text/")
+}
+
+// isTextContentType reports whether a content type holds human readable text
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json",
+		"application/xml",
+		"application/javascript",
+		"application/x-javascript",
+		"application/x-ndjson",
+		"application/graphql",
+		"application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package godebugbar
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// compress encodes data with an HTTP content coding
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %q", encoding)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeContentEncoding(t *testing.T) {
+	body := []byte(strings.Repeat("hello world ", 20))
+	gzipped := compress(t, "gzip", body)
+
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+		limit    int
+		want     []byte
+		complete bool
+		err      bool
+	}{
+		{"identity", "", body, 1024, body, true, false},
+		{"gzip", "gzip", gzipped, 1024, body, true, false},
+		{"zlib deflate", "deflate", compress(t, "deflate", body), 1024, body, true, false},
+		{"raw deflate", "deflate", compress(t, "raw-deflate", body), 1024, body, true, false},
+		{"brotli", "br", compress(t, "br", body), 1024, body, true, false},
+		{"stacked codings", "gzip, br", compress(t, "br", gzipped), 1024, body, true, false},
+		{"output over the limit", "gzip", gzipped, 10, body[:10], false, false},
+		{"truncated stream", "gzip", gzipped[:len(gzipped)/2], 1024, nil, false, false},
+		{"unknown coding", "compress", gzipped, 1024, nil, false, true},
+		{"corrupt gzip", "gzip", []byte("not gzip"), 1024, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, complete, err := decodeContentEncoding(tt.encoding, tt.data, tt.limit)
+			if (err != nil) != tt.err {
+				t.Fatalf("decodeContentEncoding() error = %v, want an error: %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if complete != tt.complete {
+				t.Errorf("complete = %v, want %v", complete, tt.complete)
+			}
+			if tt.want != nil && !bytes.Equal(got, tt.want) {
+				t.Errorf("decoded %q, want %q", got, tt.want)
+			}
+			if tt.want == nil && (len(got) == 0 || !bytes.HasPrefix(body, got)) {
+				t.Errorf("decoded %q, want a prefix of the body", got)
+			}
+		})
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	d := New(Config{})
+	base64Preview := New(Config{BinaryPreview: BodyFormatBase64})
+
+	tests := []struct {
+		name        string
+		d           *DebugBar
+		data        []byte
+		contentType string
+		encoding    string
+		truncated   bool
+		want        string
+		format      string
+		wantTrunc   bool
+	}{
+		{"empty", d, nil, "text/plain", "", false, "", "", false},
+		{"json", d, []byte(`{"a":1}`), "application/json", "", false, `{"a":1}`, BodyFormatText, false},
+		{"sniffed text", d, []byte("plain words"), "", "", false, "plain words", BodyFormatText, false},
+		{"gzip json", d, compress(t, "gzip", []byte(`{"a":1}`)), "application/json", "gzip", false, `{"a":1}`, BodyFormatText, false},
+		{"cut multi-byte rune", d, []byte("caf\xc3"), "text/plain", "", true, "caf�", BodyFormatText, true},
+		{"binary", d, []byte{0, 1, 2}, "application/octet-stream", "", false, "00000000  00 01 02                                          |...|\n", BodyFormatHex, false},
+		{"base64 binary", base64Preview, []byte{0, 1, 2}, "image/png", "", false, "AAEC", BodyFormatBase64, false},
+		{"long binary", base64Preview, make([]byte, 2000), "image/png", "", false, base64.StdEncoding.EncodeToString(make([]byte, binaryPreviewSize)), BodyFormatBase64, true},
+		{"unknown coding", base64Preview, []byte("xyz"), "text/plain", "compress", false, "eHl6", BodyFormatBase64, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, format, truncated := tt.d.renderBody(tt.data, tt.contentType, tt.encoding, 1024, tt.truncated)
+			if got != tt.want || format != tt.format || truncated != tt.wantTrunc {
+				t.Errorf("renderBody() = %q, %q, %v, want %q, %q, %v", got, format, truncated, tt.want, tt.format, tt.wantTrunc)
+			}
+		})
+	}
+}
+
+func TestBodyDecoderRegistry(t *testing.T) {
+	d := New(Config{})
+	d.RegisterBodyDecoder("image/*", BodyDecoderFunc(func(contentType string, body []byte) (string, error) {
+		return `{"image":true}`, nil
+	}))
+	d.RegisterBodyDecoder("Application/X-Broken", BodyDecoderFunc(func(contentType string, body []byte) (string, error) {
+		return "", errors.New("broken")
+	}))
+
+	if got, format, _ := d.renderBody([]byte{0x89, 'P'}, "image/png", "", 1024, false); got != `{"image":true}` || format != BodyFormatDecoded {
+		t.Errorf("wildcard decoder gave %q as %q", got, format)
+	}
+	if _, format, _ := d.renderBody([]byte{0x89, 'P'}, "image/png", "", 1024, true); format == BodyFormatDecoded {
+		t.Error("a truncated body was decoded")
+	}
+	if _, format, _ := d.renderBody([]byte{0, 1}, "application/x-broken", "", 1024, false); format != BodyFormatHex {
+		t.Errorf("failed decoder gave format %q, want the binary preview", format)
+	}
+}
+
+// grpcWebFrame builds a gRPC-web frame
+func grpcWebFrame(flag byte, data []byte) []byte {
+	frame := []byte{flag, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+func TestProtobufDecoderRedaction(t *testing.T) {
+	d := New(Config{Redaction: &RedactionConfig{BodyPaths: []string{"name"}}})
+	desc := (&descriptorpb.EnumValueDescriptorProto{}).ProtoReflect().Descriptor()
+	d.RegisterBodyDecoder("application/grpc-web+proto", NewProtobufDecoder(desc))
+
+	var body []byte
+	for i, name := range []string{"first-secret", "second-secret"} {
+		data, err := proto.Marshal(&descriptorpb.EnumValueDescriptorProto{Name: proto.String(name), Number: proto.Int32(int32(i))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = append(body, grpcWebFrame(0, data)...)
+	}
+	body = append(body, grpcWebFrame(0x80, []byte("grpc-status: 0\r\n"))...)
+
+	rendered, format, _ := d.renderBody(body, "application/grpc-web+proto", "", 1024, false)
+	rendered = d.redactRenderedBody("application/grpc-web+proto", format, rendered)
+	if format != BodyFormatDecoded {
+		t.Fatalf("format = %q, want decoded", format)
+	}
+	if strings.Contains(rendered, "secret") {
+		t.Errorf("a message wasn't redacted:\n%s", rendered)
+	}
+	if strings.Count(rendered, DefaultRedactionReplacement) != 2 || !strings.Contains(rendered, `"number":1`) || !strings.HasSuffix(rendered, "grpc-status: 0") {
+		t.Errorf("rendered body is missing messages:\n%s", rendered)
+	}
+
+	if _, err := NewProtobufDecoder(desc).Decode("application/grpc-web+proto", body[:3]); err == nil {
+		t.Error("a truncated frame decoded")
+	}
+	if _, err := NewProtobufDecoder(nil).Decode("application/x-protobuf", nil); err == nil {
+		t.Error("a body without a message type decoded")
+	}
+}
@@ -0,0 +1,454 @@
+package godebugbar
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Placeholder keys used by the ValueEncoder to annotate values that could
+// not be represented directly
+const (
+	EncodedTypeKey        = "$type"
+	EncodedPlaceholderKey = "$placeholder"
+	EncodedTruncatedKey   = "$truncated"
+)
+
+// ValueEncoder converts arbitrary Go values into representations that are
+// always safe to marshal as JSON. Values that can't be encoded, such as
+// channels, functions and cyclic references, are replaced with annotated
+// placeholders instead of failing the whole message.
+type ValueEncoder struct {
+	// MaxDepth is the maximum nesting depth of maps, slices and structs
+	MaxDepth int
+
+	// MaxItems is the maximum number of elements kept per map or slice
+	MaxItems int
+
+	// MaxStringLength is the maximum length of a string in bytes
+	MaxStringLength int
+
+	// MaxBinarySize is the maximum number of bytes of binary data kept
+	MaxBinarySize int
+
+	// MaxNodes is the maximum number of values encoded in total by one
+	// Encode, EncodeMap or EncodeSlice call, bounding the output of wide
+	// and deep values. Zero means no limit.
+	MaxNodes int
+}
+
+// DefaultValueEncoder returns a ValueEncoder with the default limits
+func DefaultValueEncoder() *ValueEncoder {
+	return &ValueEncoder{
+		MaxDepth:        8,
+		MaxItems:        100,
+		MaxStringLength: 4096,
+		MaxBinarySize:   1024,
+		MaxNodes:        10000,
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	valuerType     = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	errorInterface = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Encode returns a JSON-safe representation of v
+func (e *ValueEncoder) Encode(v any) (result any) {
+	return e.newState().encodeTop(v)
+}
+
+// EncodeMap returns a JSON-safe copy of m. The values share one MaxNodes
+// budget.
+func (e *ValueEncoder) EncodeMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	state := e.newState()
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	// Sort so the budget runs out at the same place every time
+	sort.Strings(keys)
+
+	result := make(map[string]any, len(m))
+	for _, key := range keys {
+		result[key] = state.encodeTop(m[key])
+	}
+	return result
+}
+
+// EncodeSlice returns a JSON-safe copy of s. The values share one
+// MaxNodes budget.
+func (e *ValueEncoder) EncodeSlice(s []any) []any {
+	if s == nil {
+		return nil
+	}
+	state := e.newState()
+	result := make([]any, len(s))
+	for i, value := range s {
+		result[i] = state.encodeTop(value)
+	}
+	return result
+}
+
+// encodeState tracks the pointers on the current path for cycle detection
+// and the number of values encoded so far
+type encodeState struct {
+	encoder *ValueEncoder
+	visited map[uintptr]bool
+	nodes   int
+}
+
+// newState starts encoding with a fresh budget
+func (e *ValueEncoder) newState() *encodeState {
+	return &encodeState{
+		encoder: e,
+		visited: make(map[uintptr]bool),
+	}
+}
+
+// encodeTop encodes a top-level value, turning panics into placeholders
+func (s *encodeState) encodeTop(v any) (result any) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = placeholder(reflect.TypeOf(v), fmt.Sprintf("panic while encoding: %v", r))
+		}
+	}()
+	return s.encode(reflect.ValueOf(v), 0)
+}
+
+func (s *encodeState) encode(v reflect.Value, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+
+	t := v.Type()
+
+	s.nodes++
+	if max := s.encoder.MaxNodes; max > 0 && s.nodes > max {
+		return placeholder(t, "output budget exceeded")
+	}
+
+	// Check special types before falling back to the kind
+	switch {
+	case t == timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano)
+	case t == durationType:
+		return time.Duration(v.Int()).String()
+	case t.Implements(valuerType) && v.CanInterface():
+		return s.encodeValuer(v, depth)
+	case t.Implements(errorInterface) && v.CanInterface():
+		if isNilValue(v) {
+			return nil
+		}
+		return v.Interface().(error).Error()
+	case t.Implements(marshalerType) && v.CanInterface():
+		if raw, ok := s.encodeMarshaler(v); ok {
+			return raw
+		}
+	}
+
+	if isBytes(t) {
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		return s.encodeBinary(t, bytesOf(v))
+	}
+
+	if depth > s.encoder.MaxDepth {
+		return map[string]any{
+			EncodedTypeKey:        t.String(),
+			EncodedPlaceholderKey: "max depth exceeded",
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Sprintf("%v", f)
+		}
+		return f
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprintf("%v", v.Complex())
+	case reflect.String:
+		return s.encodeString(v.String())
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return s.encode(v.Elem(), depth)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		ptr := v.Pointer()
+		if s.visited[ptr] {
+			return placeholder(t, "cycle detected")
+		}
+		s.visited[ptr] = true
+		defer delete(s.visited, ptr)
+		return s.encode(v.Elem(), depth)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return nil
+			}
+			ptr := v.Pointer()
+			if s.visited[ptr] && v.Len() > 0 {
+				return placeholder(t, "cycle detected")
+			}
+			s.visited[ptr] = true
+			defer delete(s.visited, ptr)
+		}
+		return s.encodeList(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		ptr := v.Pointer()
+		if s.visited[ptr] {
+			return placeholder(t, "cycle detected")
+		}
+		s.visited[ptr] = true
+		defer delete(s.visited, ptr)
+		return s.encodeMap(v, depth)
+	case reflect.Struct:
+		return s.encodeStruct(v, depth)
+	default:
+		// Channels, functions and unsafe pointers have no JSON form
+		return placeholder(t, "unsupported type")
+	}
+}
+
+func (s *encodeState) encodeString(str string) any {
+	if !utf8.ValidString(str) {
+		return s.encodeBinary(reflect.TypeOf(str), []byte(str))
+	}
+	max := s.encoder.MaxStringLength
+	if max > 0 && len(str) > max {
+		// Avoid cutting a multi-byte rune in half
+		cut := max
+		for cut > 0 && !utf8.RuneStart(str[cut]) {
+			cut--
+		}
+		return str[:cut] + fmt.Sprintf("... (%d bytes truncated)", len(str)-cut)
+	}
+	return str
+}
+
+func (s *encodeState) encodeBinary(t reflect.Type, data []byte) any {
+	result := map[string]any{
+		EncodedTypeKey: t.String(),
+		"size":         len(data),
+	}
+	max := s.encoder.MaxBinarySize
+	if max > 0 && len(data) > max {
+		data = data[:max]
+		result[EncodedTruncatedKey] = true
+	}
+	result["base64"] = base64.StdEncoding.EncodeToString(data)
+	return result
+}
+
+func (s *encodeState) encodeValuer(v reflect.Value, depth int) (result any) {
+	if isNilValue(v) {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result = placeholder(v.Type(), fmt.Sprintf("panic in Value(): %v", r))
+		}
+	}()
+
+	value, err := v.Interface().(driver.Valuer).Value()
+	if err != nil {
+		return placeholder(v.Type(), "Value() error: "+err.Error())
+	}
+	if value == nil {
+		return nil
+	}
+
+	// Guard against Valuers that return themselves
+	inner := reflect.ValueOf(value)
+	if inner.Type() == v.Type() {
+		return placeholder(v.Type(), "recursive Value()")
+	}
+	return s.encode(inner, depth+1)
+}
+
+func (s *encodeState) encodeMarshaler(v reflect.Value) (result any, ok bool) {
+	if isNilValue(v) {
+		return nil, true
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result, ok = nil, false
+		}
+	}()
+
+	data, err := v.Interface().(json.Marshaler).MarshalJSON()
+	if err != nil || !json.Valid(data) {
+		return nil, false
+	}
+	max := s.encoder.MaxStringLength
+	if max > 0 && len(data) > max {
+		return nil, false
+	}
+	return json.RawMessage(data), true
+}
+
+func (s *encodeState) encodeList(v reflect.Value, depth int) any {
+	n := v.Len()
+	limit := n
+	if max := s.encoder.MaxItems; max > 0 && n > max {
+		limit = max
+	}
+
+	result := make([]any, 0, limit+1)
+	for i := 0; i < limit; i++ {
+		result = append(result, s.encode(v.Index(i), depth+1))
+	}
+	if limit < n {
+		result = append(result, map[string]any{
+			EncodedTruncatedKey: fmt.Sprintf("%d more items", n-limit),
+		})
+	}
+	return result
+}
+
+func (s *encodeState) encodeMap(v reflect.Value, depth int) any {
+	keys := v.MapKeys()
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = fmt.Sprint(key.Interface())
+	}
+	dedupeKeyNames(names, keys)
+
+	// Sort so truncation is deterministic
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return names[order[a]] < names[order[b]]
+	})
+
+	limit := len(keys)
+	if max := s.encoder.MaxItems; max > 0 && limit > max {
+		limit = max
+	}
+
+	result := make(map[string]any, limit+1)
+	for _, i := range order[:limit] {
+		result[names[i]] = s.encode(v.MapIndex(keys[i]), depth+1)
+	}
+	if limit < len(keys) {
+		result[EncodedTruncatedKey] = fmt.Sprintf("%d more keys", len(keys)-limit)
+	}
+	return result
+}
+
+func (s *encodeState) encodeStruct(v reflect.Value, depth int) any {
+	t := v.Type()
+	result := map[string]any{
+		EncodedTypeKey: t.String(),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		result[name] = s.encode(v.Field(i), depth+1)
+	}
+	return result
+}
+
+// dedupeKeyNames makes map key names unique when different keys print the
+// same, such as 1 and "1" in a map[any]any, by adding the key's type and
+// then a counter
+func dedupeKeyNames(names []string, keys []reflect.Value) {
+	seen := make(map[string]int, len(names))
+	for _, name := range names {
+		seen[name]++
+	}
+	for i, name := range names {
+		if seen[name] == 1 {
+			continue
+		}
+		keyType := keys[i].Type()
+		if keys[i].Kind() == reflect.Interface && !keys[i].IsNil() {
+			keyType = keys[i].Elem().Type()
+		}
+		unique := fmt.Sprintf("%s (%s)", name, keyType)
+		for n := 2; seen[unique] > 0; n++ {
+			unique = fmt.Sprintf("%s (%s #%d)", name, keyType, n)
+		}
+		seen[unique]++
+		names[i] = unique
+	}
+}
+
+// placeholder builds the value used in place of something that can't be encoded
+func placeholder(t reflect.Type, reason string) map[string]any {
+	typeName := "<nil>"
+	if t != nil {
+		typeName = t.String()
+	}
+	return map[string]any{
+		EncodedTypeKey:        typeName,
+		EncodedPlaceholderKey: reason,
+	}
+}
+
+func isBytes(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// bytesOf returns the contents of a byte slice or array
+func bytesOf(v reflect.Value) []byte {
+	if v.Kind() == reflect.Slice {
+		return v.Bytes()
+	}
+	data := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(data), v)
+	return data
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
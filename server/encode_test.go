@@ -0,0 +1,200 @@
+package godebugbar
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testNode struct {
+	Name   string    `json:"name"`
+	Next   *testNode `json:"next"`
+	Secret string    `json:"-"`
+	hidden string
+}
+
+type testValuer struct {
+	value driver.Value
+	err   error
+}
+
+func (v testValuer) Value() (driver.Value, error) {
+	return v.value, v.err
+}
+
+type testPanicValuer struct{}
+
+func (testPanicValuer) Value() (driver.Value, error) {
+	panic("broken")
+}
+
+type testMarshaler struct{}
+
+func (testMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"custom":true}`), nil
+}
+
+func TestValueEncoder(t *testing.T) {
+	cyclic := &testNode{Name: "a"}
+	cyclic.Next = cyclic
+
+	selfMap := map[string]any{"a": 1}
+	selfMap["self"] = selfMap
+
+	shared := &testNode{Name: "shared"}
+
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{"nil", nil, nil},
+		{"int", 42, int64(42)},
+		{"NaN", math.NaN(), "NaN"},
+		{"infinity", math.Inf(1), "+Inf"},
+		{"complex", complex(1, 2), "(1+2i)"},
+		{"time", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "2026-01-02T03:04:05Z"},
+		{"duration", 1500 * time.Millisecond, "1.5s"},
+		{"error", errors.New("boom"), "boom"},
+		{"nil error", error(nil), nil},
+		{"channel", make(chan int), map[string]any{EncodedTypeKey: "chan int", EncodedPlaceholderKey: "unsupported type"}},
+		{"function", func() {}, map[string]any{EncodedTypeKey: "func()", EncodedPlaceholderKey: "unsupported type"}},
+		{
+			"struct fields and tags",
+			testNode{Name: "a", Secret: "s", hidden: "h"},
+			map[string]any{EncodedTypeKey: "godebugbar.testNode", "name": "a", "next": nil},
+		},
+		{
+			"pointer cycle",
+			cyclic,
+			map[string]any{
+				EncodedTypeKey: "godebugbar.testNode",
+				"name":         "a",
+				"next":         map[string]any{EncodedTypeKey: "*godebugbar.testNode", EncodedPlaceholderKey: "cycle detected"},
+			},
+		},
+		{
+			"map cycle",
+			selfMap,
+			map[string]any{
+				"a":    int64(1),
+				"self": map[string]any{EncodedTypeKey: "map[string]interface {}", EncodedPlaceholderKey: "cycle detected"},
+			},
+		},
+		{
+			"shared pointer is not a cycle",
+			[]any{shared, shared},
+			[]any{
+				map[string]any{EncodedTypeKey: "godebugbar.testNode", "name": "shared", "next": nil},
+				map[string]any{EncodedTypeKey: "godebugbar.testNode", "name": "shared", "next": nil},
+			},
+		},
+		{"valuer", testValuer{value: "v"}, "v"},
+		{"valuer error", testValuer{err: errors.New("bad")}, map[string]any{EncodedTypeKey: "godebugbar.testValuer", EncodedPlaceholderKey: "Value() error: bad"}},
+		{"valuer panic", testPanicValuer{}, map[string]any{EncodedTypeKey: "godebugbar.testPanicValuer", EncodedPlaceholderKey: "panic in Value(): broken"}},
+		{"marshaler", testMarshaler{}, json.RawMessage(`{"custom":true}`)},
+		{"bytes", []byte("hi"), map[string]any{EncodedTypeKey: "[]uint8", "size": 2, "base64": "aGk="}},
+		{"invalid utf-8", "\xff", map[string]any{EncodedTypeKey: "string", "size": 1, "base64": "/w=="}},
+		{
+			"keys that print the same",
+			map[any]any{1: "int", "1": "string"},
+			map[string]any{"1 (int)": "int", "1 (string)": "string"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DefaultValueEncoder().Encode(tt.value)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Encode() = %#v, want %#v", got, tt.want)
+			}
+			if _, err := json.Marshal(got); err != nil {
+				t.Errorf("encoded value doesn't marshal: %v", err)
+			}
+		})
+	}
+}
+
+func TestValueEncoderLimits(t *testing.T) {
+	deep := map[string]any{"value": "bottom"}
+	for range 3 {
+		deep = map[string]any{"next": deep}
+	}
+
+	tests := []struct {
+		name    string
+		encoder ValueEncoder
+		value   any
+		want    any
+	}{
+		{
+			"max depth",
+			ValueEncoder{MaxDepth: 1},
+			deep,
+			map[string]any{"next": map[string]any{"next": map[string]any{
+				EncodedTypeKey:        "interface {}",
+				EncodedPlaceholderKey: "max depth exceeded",
+			}}},
+		},
+		{
+			"max items in a slice",
+			ValueEncoder{MaxItems: 2, MaxDepth: 8},
+			[]int{1, 2, 3, 4},
+			[]any{int64(1), int64(2), map[string]any{EncodedTruncatedKey: "2 more items"}},
+		},
+		{
+			"max items in a map",
+			ValueEncoder{MaxItems: 1, MaxDepth: 8},
+			map[string]int{"b": 2, "a": 1},
+			map[string]any{"a": int64(1), EncodedTruncatedKey: "1 more keys"},
+		},
+		{
+			"max string length keeps whole runes",
+			ValueEncoder{MaxStringLength: 4},
+			"abcé",
+			"abc... (2 bytes truncated)",
+		},
+		{
+			"max binary size",
+			ValueEncoder{MaxBinarySize: 2},
+			[]byte("abcd"),
+			map[string]any{EncodedTypeKey: "[]uint8", "size": 4, "base64": "YWI=", EncodedTruncatedKey: true},
+		},
+		{
+			"max nodes",
+			ValueEncoder{MaxNodes: 3, MaxDepth: 8},
+			[]int{1, 2, 3},
+			[]any{int64(1), int64(2), map[string]any{EncodedTypeKey: "int", EncodedPlaceholderKey: "output budget exceeded"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.encoder.Encode(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Encode() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueEncoderSharedBudget(t *testing.T) {
+	encoder := &ValueEncoder{MaxNodes: 3, MaxDepth: 8}
+
+	encoded := encoder.EncodeMap(map[string]any{"a": 1, "b": 2, "c": 3, "d": 4})
+	if _, ok := encoded["d"].(map[string]any); !ok || encoded["c"] != int64(3) {
+		t.Errorf("EncodeMap() = %v, want the budget to run out at d", encoded)
+	}
+
+	args := encoder.EncodeSlice([]any{strings.Repeat("x", 3), 1, 2, 3})
+	if _, ok := args[3].(map[string]any); !ok {
+		t.Errorf("EncodeSlice() = %v, want the budget to run out at the last arg", args)
+	}
+	if encoder.EncodeMap(nil) != nil || encoder.EncodeSlice(nil) != nil {
+		t.Error("encoding nil didn't return nil")
+	}
+}
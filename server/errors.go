@@ -3,7 +3,6 @@ package godebugbar
 import (
 	"fmt"
 	"runtime"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -66,10 +65,10 @@ func (d *DebugBar) logError(c *gin.Context, err error, errType string, ctx map[s
 	errorInfo := ErrorInfo{
 		ID:        uuid.New().String(),
 		RequestID: reqInfo.ID,
-		Message:   err.Error(),
+		Message:   d.redactor.RedactString(err.Error()),
 		Type:      errType,
-		Timestamp: time.Now(),
-		Context:   ctx,
+		Timestamp: d.clock(),
+		Context:   d.redactor.RedactValues(d.encoder.EncodeMap(ctx)),
 	}
 
 	// Capture stack trace
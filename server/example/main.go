@@ -26,8 +26,8 @@ func main() {
 		MaxRequests:        100,
 		CaptureRequestBody: true,
 		MaxBodySize:        64 * 1024,
-		AllowedOrigins:     []string{"*"},
 	})
+	defer debugBar.Close()
 
 	// Initialize GORM with SQLite
 	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
@@ -81,7 +81,8 @@ func main() {
 	r.POST("/users", func(c *gin.Context) {
 		var user User
 		if err := c.ShouldBindJSON(&user); err != nil {
-			debugBar.LogWarning(c, "Invalid user data: "+err.Error())
+			// Records each failed field, tag and value
+			debugBar.LogValidationErrors(c, err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -0,0 +1,94 @@
+package godebugbar
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Field is a named list of values, preserving repeated entries such as
+// multiple Set-Cookie headers or ?tag=a&tag=b query parameters
+type Field struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Fields is an ordered list of multi-valued fields
+type Fields []Field
+
+// Get returns the first value of the named field, or an empty string
+func (f Fields) Get(name string) string {
+	if values := f.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values returns all values of the named field
+func (f Fields) Values(name string) []string {
+	for _, field := range f {
+		if field.Name == name {
+			return field.Values
+		}
+	}
+	return nil
+}
+
+// Flatten returns the fields as a map holding the first value of each,
+// matching the legacy headers and query_params shape
+func (f Fields) Flatten() map[string]string {
+	result := make(map[string]string, len(f))
+	for _, field := range f {
+		if len(field.Values) > 0 {
+			result[field.Name] = field.Values[0]
+		}
+	}
+	return result
+}
+
+// headerFields converts HTTP headers to fields sorted by name
+func headerFields(header http.Header) Fields {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make(Fields, 0, len(names))
+	for _, name := range names {
+		values := make([]string, len(header[name]))
+		copy(values, header[name])
+		fields = append(fields, Field{Name: name, Values: values})
+	}
+	return fields
+}
+
+// queryFields parses a raw query string into fields, keeping parameters in
+// the order they first appear and values in the order they were given
+func queryFields(rawQuery string) Fields {
+	fields := make(Fields, 0)
+	index := make(map[string]int)
+
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+
+		if i, ok := index[key]; ok {
+			fields[i].Values = append(fields[i].Values, value)
+			continue
+		}
+		index[key] = len(fields)
+		fields = append(fields, Field{Name: key, Values: []string{value}})
+	}
+	return fields
+}
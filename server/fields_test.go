@@ -0,0 +1,106 @@
+package godebugbar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQueryFields(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Fields
+	}{
+		{"", Fields{}},
+		{"a=1", Fields{{Name: "a", Values: []string{"1"}}}},
+		{"tag=b&x=1&tag=a", Fields{{Name: "tag", Values: []string{"b", "a"}}, {Name: "x", Values: []string{"1"}}}},
+		{"flag&empty=", Fields{{Name: "flag", Values: []string{""}}, {Name: "empty", Values: []string{""}}}},
+		{"q=a+b%26c&na%6De=1", Fields{{Name: "q", Values: []string{"a b&c"}}, {Name: "name", Values: []string{"1"}}}},
+		{"bad=%zz", Fields{{Name: "bad", Values: []string{"%zz"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := queryFields(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queryFields(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderFields(t *testing.T) {
+	header := http.Header{
+		"X-B":        {"2"},
+		"Set-Cookie": {"a=1", "b=2"},
+		"Accept":     {"text/html"},
+	}
+	fields := headerFields(header)
+
+	want := Fields{
+		{Name: "Accept", Values: []string{"text/html"}},
+		{Name: "Set-Cookie", Values: []string{"a=1", "b=2"}},
+		{Name: "X-B", Values: []string{"2"}},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("headerFields() = %v, want %v", fields, want)
+	}
+
+	// The fields are a copy the debug bar can redact
+	fields[1].Values[0] = "masked"
+	if header["Set-Cookie"][0] != "a=1" {
+		t.Error("headerFields() shares values with the header")
+	}
+
+	if got := fields.Get("Set-Cookie"); got != "masked" {
+		t.Errorf("Get() = %q, want the first value", got)
+	}
+	if fields.Values("Missing") != nil || fields.Get("Missing") != "" {
+		t.Error("a missing field has values")
+	}
+	if flat := fields.Flatten(); !reflect.DeepEqual(flat, map[string]string{"Accept": "text/html", "Set-Cookie": "masked", "X-B": "2"}) {
+		t.Errorf("Flatten() = %v", flat)
+	}
+}
+
+func TestMultiValueCapture(t *testing.T) {
+	for _, multiValueOnly := range []bool{false, true} {
+		config := testConfig()
+		config.MultiValueOnly = multiValueOnly
+		d := New(config)
+		engine := testEngine(t, d)
+		engine.GET("/items", func(c *gin.Context) {
+			c.Writer.Header().Add("Set-Cookie", "a=1")
+			c.Writer.Header().Add("Set-Cookie", "b=2")
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/items?tag=a&tag=b", nil)
+		req.Header.Add("Accept", "text/html")
+		req.Header.Add("Accept", "application/json")
+		_, captured := serve(t, d, engine, req)
+		if captured == nil {
+			t.Fatal("request wasn't captured")
+		}
+
+		if got := captured.QueryValues.Values("tag"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("query tag = %v, want both values", got)
+		}
+		if got := captured.RequestHeaders.Values("Accept"); len(got) != 2 {
+			t.Errorf("Accept header = %v, want both values", got)
+		}
+		if got := captured.ResponseHeaders.Values("Set-Cookie"); len(got) != 2 {
+			t.Errorf("Set-Cookie header = %v, want both values", got)
+		}
+
+		if multiValueOnly {
+			if captured.Headers != nil || captured.QueryParams != nil {
+				t.Errorf("MultiValueOnly kept the legacy maps: %v %v", captured.Headers, captured.QueryParams)
+			}
+		} else if captured.QueryParams["tag"] != "a" || captured.Headers["Accept"] != "text/html" {
+			t.Errorf("legacy maps = %v %v, want the first values", captured.Headers, captured.QueryParams)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package godebugbar
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sync"
+)
+
+// sniffLength is the number of bytes used to detect a file's content type
+const sniffLength = 512
+
+// FormData holds the parsed fields of a form request body
+type FormData struct {
+	Fields    Fields     `json:"fields"`
+	Files     []FileInfo `json:"files,omitempty"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// FileInfo describes an uploaded file. The file contents are not kept.
+type FileInfo struct {
+	Field        string `json:"field"`
+	Filename     string `json:"filename"`
+	ContentType  string `json:"content_type,omitempty"`
+	DetectedType string `json:"detected_type,omitempty"`
+	Size         int64  `json:"size"`
+
+	// SizeTruncated is set when neither the captured body nor the body the
+	// handler read contained the whole file, so Size is a lower bound
+	SizeTruncated bool `json:"size_truncated,omitempty"`
+}
+
+// partSizer measures the file parts of a multipart body as the handler
+// reads it, so file sizes aren't limited to the captured prefix
+type partSizer struct {
+	writer *io.PipeWriter
+	done   chan struct{}
+	once   sync.Once
+	files  []FileInfo
+}
+
+// newPartSizer starts measuring a multipart body with the given boundary
+func newPartSizer(boundary string) *partSizer {
+	reader, writer := io.Pipe()
+	s := &partSizer{writer: writer, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+		parts := multipart.NewReader(reader, boundary)
+		for {
+			part, err := parts.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FileName() == "" {
+				if _, err := io.Copy(io.Discard, part); err != nil {
+					break
+				}
+				continue
+			}
+			file, complete := readFileInfo(part)
+			file.Field = part.FormName()
+			file.Filename = part.FileName()
+			s.files = append(s.files, file)
+			if !complete {
+				break
+			}
+		}
+		// Keep the handler's reads from blocking on a malformed body
+		io.Copy(io.Discard, reader)
+	}()
+	return s
+}
+
+// Write feeds body bytes read by the handler to the parser
+func (s *partSizer) Write(p []byte) (int, error) {
+	// Errors only mean parsing stopped, the handler's read still counts
+	s.writer.Write(p)
+	return len(p), nil
+}
+
+// finish stops measuring and returns the files seen in the body
+func (s *partSizer) finish() []FileInfo {
+	s.once.Do(func() {
+		s.writer.Close()
+		<-s.done
+	})
+	return s.files
+}
+
+// parseForm parses a captured urlencoded or multipart request body. It works
+// on the debug bar's copy of the body, so the handler's body is untouched.
+func (d *DebugBar) parseForm(contentType string, data []byte, truncated bool) *FormData {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		return d.parseURLEncodedForm(data, truncated)
+	case "multipart/form-data":
+		if params["boundary"] == "" {
+			return nil
+		}
+		return d.parseMultipartForm(data, params["boundary"], truncated)
+	}
+	return nil
+}
+
+func (d *DebugBar) parseURLEncodedForm(data []byte, truncated bool) *FormData {
+	fields := queryFields(string(data))
+	for i, field := range fields {
+		for j, value := range field.Values {
+			fields[i].Values[j] = d.redactor.RedactFormValue(field.Name, value)
+		}
+	}
+	return &FormData{
+		Fields:    fields,
+		Truncated: truncated,
+	}
+}
+
+func (d *DebugBar) parseMultipartForm(data []byte, boundary string, truncated bool) *FormData {
+	form := &FormData{
+		Fields:    make(Fields, 0),
+		Truncated: truncated,
+	}
+	index := make(map[string]int)
+	maxValue := int64(d.config.MaxBodySize)
+
+	reader := multipart.NewReader(bytes.NewReader(data), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The captured body ended part way through the form
+			form.Truncated = true
+			break
+		}
+
+		name := part.FormName()
+		if filename := part.FileName(); filename != "" {
+			file, complete := readFileInfo(part)
+			file.Field = name
+			file.Filename = d.redactor.RedactString(filename)
+			form.Files = append(form.Files, file)
+			if !complete {
+				form.Truncated = true
+				break
+			}
+			continue
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxValue))
+		if err != nil {
+			form.Truncated = true
+		}
+
+		redacted := d.redactor.RedactFormValue(name, string(value))
+		if i, ok := index[name]; ok {
+			form.Fields[i].Values = append(form.Fields[i].Values, redacted)
+		} else {
+			index[name] = len(form.Fields)
+			form.Fields = append(form.Fields, Field{Name: name, Values: []string{redacted}})
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	return form
+}
+
+// mergeStreamedFiles updates the files parsed from the captured prefix with
+// the sizes measured from the full body, adding files the prefix didn't
+// reach
+func (d *DebugBar) mergeStreamedFiles(form *FormData, files []FileInfo) {
+	if form == nil {
+		return
+	}
+	for i, file := range files {
+		if i >= len(form.Files) {
+			file.Filename = d.redactor.RedactString(file.Filename)
+			form.Files = append(form.Files, file)
+			continue
+		}
+		if file.Size >= form.Files[i].Size {
+			form.Files[i].Size = file.Size
+			form.Files[i].SizeTruncated = file.SizeTruncated
+		}
+	}
+}
+
+// readFileInfo reads a file part to record its size and sniffed content
+// type. It reports whether the whole part was available.
+func readFileInfo(part *multipart.Part) (FileInfo, bool) {
+	file := FileInfo{
+		ContentType: part.Header.Get("Content-Type"),
+	}
+
+	head := make([]byte, 0, sniffLength)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := part.Read(buf)
+		if remaining := sniffLength - len(head); remaining > 0 {
+			head = append(head, buf[:min(n, remaining)]...)
+		}
+		file.Size += int64(n)
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.SizeTruncated = true
+			break
+		}
+	}
+
+	if len(head) > 0 {
+		file.DetectedType = http.DetectContentType(head)
+	}
+	return file, !file.SizeTruncated
+}
@@ -0,0 +1,147 @@
+package godebugbar
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testMultipart builds a multipart body with a text field, a password field
+// and a PNG file of the given size
+func testMultipart(t *testing.T, fileSize int) (string, []byte) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("name", "ada")
+	writer.WriteField("password", "hunter2")
+	file, err := writer.CreateFormFile("avatar", "me.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, fileSize-8)...)
+	file.Write(png)
+	writer.Close()
+	return writer.FormDataContentType(), body.Bytes()
+}
+
+func TestParseForm(t *testing.T) {
+	d := New(Config{MaxBodySize: 1024})
+	mask := DefaultRedactionReplacement
+
+	t.Run("urlencoded", func(t *testing.T) {
+		form := d.parseForm("application/x-www-form-urlencoded", []byte("tag=a&tag=b&password=x"), false)
+		want := Fields{{Name: "tag", Values: []string{"a", "b"}}, {Name: "password", Values: []string{mask}}}
+		if form == nil || !reflect.DeepEqual(form.Fields, want) {
+			t.Errorf("parseForm() = %+v, want %v", form, want)
+		}
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		contentType, body := testMultipart(t, 100)
+		form := d.parseForm(contentType, body, false)
+		if form == nil {
+			t.Fatal("parseForm() = nil")
+		}
+		want := Fields{{Name: "name", Values: []string{"ada"}}, {Name: "password", Values: []string{mask}}}
+		if !reflect.DeepEqual(form.Fields, want) || form.Truncated {
+			t.Errorf("fields = %v (truncated %v), want %v", form.Fields, form.Truncated, want)
+		}
+		wantFile := FileInfo{
+			Field:        "avatar",
+			Filename:     "me.png",
+			ContentType:  "application/octet-stream",
+			DetectedType: "image/png",
+			Size:         100,
+		}
+		if len(form.Files) != 1 || form.Files[0] != wantFile {
+			t.Errorf("files = %+v, want %+v", form.Files, wantFile)
+		}
+	})
+
+	t.Run("truncated multipart", func(t *testing.T) {
+		contentType, body := testMultipart(t, 1000)
+		form := d.parseForm(contentType, body[:len(body)-500], true)
+		if form == nil || !form.Truncated || len(form.Files) != 1 || !form.Files[0].SizeTruncated {
+			t.Errorf("parseForm() = %+v, want a truncated form with a partial file", form)
+		}
+	})
+
+	for _, contentType := range []string{"application/json", "multipart/form-data", "not a type;;"} {
+		if form := d.parseForm(contentType, []byte("a=1"), false); form != nil {
+			t.Errorf("parseForm(%q) = %+v, want nil", contentType, form)
+		}
+	}
+}
+
+func TestPartSizer(t *testing.T) {
+	contentType, body := testMultipart(t, 100000)
+	boundary := strings.TrimPrefix(contentType, "multipart/form-data; boundary=")
+
+	sizer := newPartSizer(boundary)
+	// Feed the body in small writes like a handler reading it
+	for chunk := range slices.Chunk(body, 4096) {
+		sizer.Write(chunk)
+	}
+	files := sizer.finish()
+	if len(files) != 1 || files[0].Size != 100000 || files[0].SizeTruncated || files[0].Filename != "me.png" {
+		t.Errorf("files = %+v, want the whole file", files)
+	}
+
+	// A body the handler stops reading part way leaves a lower bound
+	sizer = newPartSizer(boundary)
+	sizer.Write(body[:50000])
+	files = sizer.finish()
+	if len(files) != 1 || !files[0].SizeTruncated || files[0].Size >= 100000 {
+		t.Errorf("files = %+v, want a truncated size", files)
+	}
+	if again := sizer.finish(); len(again) != 1 {
+		t.Error("finish() isn't repeatable")
+	}
+}
+
+func TestMultipartUploadCapture(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxBodySize = 1024
+	d := New(config)
+	engine := testEngine(t, d)
+
+	var received int64
+	engine.POST("/upload", func(c *gin.Context) {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			t.Errorf("FormFile() error = %v", err)
+			return
+		}
+		received = file.Size
+	})
+
+	contentType, body := testMultipart(t, 200000)
+	req := httptest.NewRequest(http.MethodPost, "/upload", io.NopCloser(bytes.NewReader(body)))
+	req.Header.Set("Content-Type", contentType)
+	_, captured := serve(t, d, engine, req)
+	if captured == nil || captured.Form == nil {
+		t.Fatalf("captured %+v, want a form", captured)
+	}
+
+	if received != 200000 {
+		t.Errorf("handler got a %d byte file, want 200000", received)
+	}
+	if captured.RequestBody != "" {
+		t.Errorf("RequestBody = %q, want it dropped for multipart forms", captured.RequestBody)
+	}
+	files := captured.Form.Files
+	if len(files) != 1 || files[0].Size != 200000 || files[0].SizeTruncated {
+		t.Errorf("files = %+v, want the full file size measured while the handler read it", files)
+	}
+	if got := captured.Form.Fields.Get("password"); got != DefaultRedactionReplacement {
+		t.Errorf("password field = %q, want it redacted", got)
+	}
+}
@@ -125,7 +125,7 @@ func (p *GormDebugBarPlugin) beforeCallback(db *gorm.DB) {
 		return
 	}
 
-	db.InstanceSet(startTimeKey, time.Now())
+	db.InstanceSet(startTimeKey, p.debugBar.clock())
 	db.InstanceSet(queryIDKey, uuid.New().String())
 }
 
@@ -155,7 +155,7 @@ func (p *GormDebugBarPlugin) afterCallback(db *gorm.DB) {
 		return
 	}
 
-	duration := time.Since(startTime)
+	duration := p.debugBar.clock().Sub(startTime)
 
 	// Build query info
 	queryInfo := QueryInfo{
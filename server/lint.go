@@ -0,0 +1,302 @@
+package godebugbar
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// LintRule inspects a captured query and reports a problem with it.
+// Check returns an empty string when the query is fine. Rules get a
+// snapshot of the request and run without the DebugBar's lock held.
+type LintRule interface {
+	Name() string
+	Check(query QueryInfo, req *RequestInfo) string
+}
+
+// lintRuleFunc adapts a function to the LintRule interface
+type lintRuleFunc struct {
+	name string
+	fn   func(QueryInfo, *RequestInfo) string
+}
+
+func (r *lintRuleFunc) Name() string {
+	return r.name
+}
+
+func (r *lintRuleFunc) Check(query QueryInfo, req *RequestInfo) string {
+	return r.fn(query, req)
+}
+
+// NewLintRule creates a lint rule from a function
+func NewLintRule(name string, fn func(query QueryInfo, req *RequestInfo) string) LintRule {
+	return &lintRuleFunc{name: name, fn: fn}
+}
+
+// Default thresholds for the built-in lint rules
+const (
+	DefaultMaxInListItems   = 100
+	DefaultQueryInLoopLimit = 5
+)
+
+// DefaultLintRules returns the built-in lint rules
+func DefaultLintRules() []LintRule {
+	return []LintRule{
+		SelectStarRule(),
+		MissingWhereRule(),
+		LeadingWildcardRule(),
+		LargeInListRule(DefaultMaxInListItems),
+		MissingLimitRule(),
+		QueryInLoopRule(DefaultQueryInLoopLimit),
+	}
+}
+
+var (
+	selectStarPattern = regexp.MustCompile(`(?i)^\s*select\s+(distinct\s+)?(\w+\.)?\*`)
+	wherePattern      = regexp.MustCompile(`(?i)\bwhere\b`)
+	limitPattern      = regexp.MustCompile(`(?i)\b(limit|fetch\s+first|top)\b`)
+	aggregatePattern  = regexp.MustCompile(`(?i)^\s*select\s+(count|sum|avg|min|max)\s*\(`)
+	fromPattern       = regexp.MustCompile(`(?i)\bfrom\b`)
+	likePattern       = regexp.MustCompile(`(?i)\blike\s+(\?|\$\d+|'%)`)
+	inListPattern     = regexp.MustCompile(`(?i)\bin\s*\(`)
+)
+
+// SelectStarRule warns about queries that select every column
+func SelectStarRule() LintRule {
+	return NewLintRule("select_star", func(query QueryInfo, req *RequestInfo) string {
+		if selectStarPattern.MatchString(stripSQLLiterals(query.Query)) {
+			return "Query selects all columns with SELECT *"
+		}
+		return ""
+	})
+}
+
+// MissingWhereRule warns about UPDATE and DELETE statements without a WHERE clause
+func MissingWhereRule() LintRule {
+	return NewLintRule("missing_where", func(query QueryInfo, req *RequestInfo) string {
+		sql := stripSQLLiterals(query.Query)
+		verb := sqlVerb(sql)
+		if (verb == "UPDATE" || verb == "DELETE") && !wherePattern.MatchString(sql) {
+			return fmt.Sprintf("%s statement has no WHERE clause and affects every row", verb)
+		}
+		return ""
+	})
+}
+
+// LeadingWildcardRule warns about LIKE patterns that start with a wildcard
+func LeadingWildcardRule() LintRule {
+	return NewLintRule("leading_wildcard", func(query QueryInfo, req *RequestInfo) string {
+		for _, loc := range likePattern.FindAllStringSubmatchIndex(query.Query, -1) {
+			operand := query.Query[loc[2]:loc[3]]
+			if operand == "'%" {
+				return "LIKE pattern starts with a wildcard and cannot use an index"
+			}
+
+			arg, ok := placeholderArg(query.Query, loc[2], query.Args)
+			if !ok {
+				continue
+			}
+			if s, ok := arg.(string); ok && strings.HasPrefix(s, "%") {
+				return "LIKE pattern starts with a wildcard and cannot use an index"
+			}
+		}
+		return ""
+	})
+}
+
+// LargeInListRule warns about IN (...) lists with more than max items
+func LargeInListRule(max int) LintRule {
+	return NewLintRule("large_in_list", func(query QueryInfo, req *RequestInfo) string {
+		sql := stripSQLLiterals(query.Query)
+		for _, loc := range inListPattern.FindAllStringIndex(sql, -1) {
+			items := countListItems(sql[loc[1]:])
+			if items > max {
+				return fmt.Sprintf("IN list has %d items (limit %d)", items, max)
+			}
+		}
+		return ""
+	})
+}
+
+// MissingLimitRule warns about SELECT statements with neither a WHERE nor a LIMIT clause
+func MissingLimitRule() LintRule {
+	return NewLintRule("missing_limit", func(query QueryInfo, req *RequestInfo) string {
+		sql := stripSQLLiterals(query.Query)
+		if sqlVerb(sql) != "SELECT" || !fromPattern.MatchString(sql) {
+			return ""
+		}
+		if aggregatePattern.MatchString(sql) {
+			return ""
+		}
+		if !wherePattern.MatchString(sql) && !limitPattern.MatchString(sql) {
+			return "SELECT has no WHERE or LIMIT clause and may return an unbounded result set"
+		}
+		return ""
+	})
+}
+
+// QueryInLoopRule warns when the same query shape runs limit times within
+// a single request, which usually means it is being executed inside a loop
+func QueryInLoopRule(limit int) LintRule {
+	return NewLintRule("query_in_loop", func(query QueryInfo, req *RequestInfo) string {
+		shape := query.normalized()
+		count, ok := req.shapeCounts[shape]
+		if !ok {
+			// Not counted by addQuery, e.g. a request built by hand
+			for _, q := range req.Queries {
+				if q.normalized() == shape {
+					count++
+				}
+			}
+		}
+		// Only report once, when the threshold is first reached
+		if count == limit {
+			return fmt.Sprintf("Same query executed %d times in one request, it may be running inside a loop", count)
+		}
+		return ""
+	})
+}
+
+// lintQuery runs the lint rules against a query and returns any warnings
+func (d *DebugBar) lintQuery(rules []LintRule, query QueryInfo, req *RequestInfo) []ErrorInfo {
+	var warnings []ErrorInfo
+	for _, rule := range rules {
+		msg := rule.Check(query, req)
+		if msg == "" {
+			continue
+		}
+
+		ctx := map[string]any{
+			"lint_rule": rule.Name(),
+			"query_id":  query.ID,
+			"query":     query.Query,
+		}
+		if query.Source != "" {
+			ctx["source"] = query.Source
+		}
+
+		warnings = append(warnings, ErrorInfo{
+			ID:        uuid.New().String(),
+			RequestID: req.ID,
+			Message:   msg,
+			Type:      ErrorTypeWarning,
+			Timestamp: d.clock(),
+			Context:   ctx,
+		})
+	}
+	return warnings
+}
+
+// AddLintRule registers an additional query lint rule
+func (d *DebugBar) AddLintRule(rule LintRule) {
+	d.mu.Lock()
+	// Copy so snapshots taken by addQuery never see the slice change
+	d.lintRules = append(slices.Clip(d.lintRules), rule)
+	d.mu.Unlock()
+}
+
+// sqlVerb returns the upper-cased first keyword of a statement
+func sqlVerb(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// stripSQLLiterals replaces quoted string literals with empty ones so their
+// contents are not mistaken for SQL keywords
+func stripSQLLiterals(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+
+	inQuote := false
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		if ch == '\'' {
+			if inQuote && i+1 < len(sql) && sql[i+1] == '\'' {
+				// Escaped quote inside a literal
+				i++
+				continue
+			}
+			inQuote = !inQuote
+			b.WriteByte(ch)
+			continue
+		}
+		if !inQuote {
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// placeholderArg returns the argument bound to the placeholder at pos.
+// Both positional (?) and numbered ($1) placeholders are supported.
+func placeholderArg(sql string, pos int, args []any) (any, bool) {
+	if sql[pos] == '$' {
+		var n int
+		if _, err := fmt.Sscanf(sql[pos+1:], "%d", &n); err != nil || n < 1 || n > len(args) {
+			return nil, false
+		}
+		return args[n-1], true
+	}
+
+	index := strings.Count(stripSQLLiterals(sql[:pos]), "?")
+	if index >= len(args) {
+		return nil, false
+	}
+	return args[index], true
+}
+
+// countListItems counts the comma separated items of a parenthesised list.
+// s starts just after the opening parenthesis.
+func countListItems(s string) int {
+	depth := 0
+	items := 1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return items
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				items++
+			}
+		}
+	}
+	return items
+}
+
+var (
+	sqlNumberPattern      = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+	sqlPlaceholderPattern = regexp.MustCompile(`\$\d+`)
+	sqlListPattern        = regexp.MustCompile(`\(\s*\?(\s*,\s*\?)*\s*\)`)
+	sqlSpacePattern       = regexp.MustCompile(`\s+`)
+)
+
+// normalized returns the query's shape, using the one addQuery computed
+// when it is set
+func (q QueryInfo) normalized() string {
+	if q.shape != "" {
+		return q.shape
+	}
+	return normalizeSQL(q.Query)
+}
+
+// normalizeSQL reduces a query to its shape by removing literal values
+func normalizeSQL(sql string) string {
+	sql = stripSQLLiterals(sql)
+	sql = strings.ReplaceAll(sql, "''", "?")
+	sql = sqlPlaceholderPattern.ReplaceAllString(sql, "?")
+	sql = sqlNumberPattern.ReplaceAllString(sql, "?")
+	sql = sqlListPattern.ReplaceAllString(sql, "(?)")
+	sql = sqlSpacePattern.ReplaceAllString(sql, " ")
+	return strings.ToLower(strings.TrimSpace(sql))
+}
@@ -0,0 +1,113 @@
+package godebugbar
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  LintRule
+		query string
+		args  []any
+		warn  bool
+	}{
+		{"select star", SelectStarRule(), "SELECT * FROM users WHERE id = ?", nil, true},
+		{"select table star", SelectStarRule(), "select distinct u.* from users u", nil, true},
+		{"select columns", SelectStarRule(), "SELECT id, name FROM users", nil, false},
+		{"star inside a literal", SelectStarRule(), "SELECT 'select *' FROM users", nil, false},
+		{"count star", SelectStarRule(), "SELECT COUNT(*) FROM users", nil, false},
+
+		{"update without where", MissingWhereRule(), "UPDATE users SET active = false", nil, true},
+		{"delete without where", MissingWhereRule(), "delete from sessions", nil, true},
+		{"delete with where", MissingWhereRule(), "DELETE FROM sessions WHERE id = ?", nil, false},
+		{"where inside a literal", MissingWhereRule(), "UPDATE users SET note = 'where'", nil, true},
+
+		{"literal leading wildcard", LeadingWildcardRule(), "SELECT id FROM users WHERE name LIKE '%ada'", nil, true},
+		{"literal trailing wildcard", LeadingWildcardRule(), "SELECT id FROM users WHERE name LIKE 'ada%'", nil, false},
+		{"bound leading wildcard", LeadingWildcardRule(), "SELECT id FROM users WHERE a = ? AND name LIKE ?", []any{1, "%ada"}, true},
+		{"bound trailing wildcard", LeadingWildcardRule(), "SELECT id FROM users WHERE name LIKE ?", []any{"ada%"}, false},
+		{"numbered leading wildcard", LeadingWildcardRule(), "SELECT id FROM users WHERE name LIKE $2 AND a = $1", []any{1, "%ada"}, true},
+		{"missing arg", LeadingWildcardRule(), "SELECT id FROM users WHERE name LIKE ?", nil, false},
+
+		{"large in list", LargeInListRule(3), "SELECT id FROM users WHERE id IN (1, 2, 3, 4)", nil, true},
+		{"small in list", LargeInListRule(3), "SELECT id FROM users WHERE id IN (1, 2, 3)", nil, false},
+		{"nested calls in list", LargeInListRule(3), "SELECT id FROM users WHERE id IN (f(1, 2), 3)", nil, false},
+
+		{"unbounded select", MissingLimitRule(), "SELECT id FROM users", nil, true},
+		{"select with limit", MissingLimitRule(), "SELECT id FROM users LIMIT 10", nil, false},
+		{"select with where", MissingLimitRule(), "SELECT id FROM users WHERE active", nil, false},
+		{"aggregate", MissingLimitRule(), "SELECT COUNT(*) FROM users", nil, false},
+		{"select without from", MissingLimitRule(), "SELECT 1", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.rule.Check(QueryInfo{Query: tt.query, Args: tt.args}, &RequestInfo{})
+			if (msg != "") != tt.warn {
+				t.Errorf("%s.Check(%q) = %q, want a warning: %v", tt.rule.Name(), tt.query, msg, tt.warn)
+			}
+		})
+	}
+}
+
+func TestNormalizeSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM users WHERE id = 42", "select * from users where id = ?"},
+		{"SELECT * FROM users WHERE name = 'O''Brien'", "select * from users where name = ?"},
+		{"SELECT *\n  FROM users WHERE id IN (?, ?, ?)", "select * from users where id in (?)"},
+		{"SELECT * FROM users WHERE id = $1 AND age > $2", "select * from users where id = ? and age > ?"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeSQL(tt.sql); got != tt.want {
+			t.Errorf("normalizeSQL(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestQueryInLoop(t *testing.T) {
+	d := New(Config{Enabled: true, MaxRequests: 10, LintRules: []LintRule{QueryInLoopRule(3)}})
+	req := &RequestInfo{ID: "request"}
+	ctx := context.WithValue(context.Background(), DebugBarContextKey, req)
+
+	for i := range 5 {
+		d.addQuery(ctx, QueryInfo{Query: "SELECT name FROM users WHERE id = " + strings.Repeat("1", i+1)})
+	}
+	d.addQuery(ctx, QueryInfo{Query: "SELECT name FROM orders WHERE id = 1"})
+
+	if len(req.Queries) != 6 {
+		t.Fatalf("captured %d queries, want 6", len(req.Queries))
+	}
+	if len(req.Errors) != 1 {
+		t.Fatalf("got %d warnings, want one when the limit is reached", len(req.Errors))
+	}
+	warning := req.Errors[0]
+	if warning.Type != ErrorTypeWarning || warning.Context["lint_rule"] != "query_in_loop" || warning.Context["query_id"] != req.Queries[2].ID {
+		t.Errorf("warning = %+v, want a query_in_loop warning for the third query", warning)
+	}
+}
+
+func TestAddLintRule(t *testing.T) {
+	d := New(Config{Enabled: true, MaxRequests: 10, LintRules: []LintRule{}})
+	d.AddLintRule(NewLintRule("no_users", func(query QueryInfo, req *RequestInfo) string {
+		if strings.Contains(query.Query, "users") {
+			return "Query reads users"
+		}
+		return ""
+	}))
+
+	req := &RequestInfo{ID: "request"}
+	ctx := context.WithValue(context.Background(), DebugBarContextKey, req)
+	d.addQuery(ctx, QueryInfo{Query: "SELECT id FROM orders"})
+	d.addQuery(ctx, QueryInfo{Query: "SELECT id FROM users", Source: "handlers.go:12"})
+
+	if len(req.Errors) != 1 || req.Errors[0].Message != "Query reads users" || req.Errors[0].Context["source"] != "handlers.go:12" {
+		t.Errorf("warnings = %+v, want one from the custom rule", req.Errors)
+	}
+}
@@ -3,82 +3,146 @@ package godebugbar
 import (
 	"bytes"
 	"context"
-	"io"
+	"mime"
+	"net/http"
 	"runtime"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-// responseWriter wraps gin.ResponseWriter to capture response size
+// responseWriter wraps gin.ResponseWriter to capture response size and,
+// when enabled, the first limit bytes of the response body
 type responseWriter struct {
 	gin.ResponseWriter
-	size int
+	size      int
+	body      *bytes.Buffer
+	limit     int
+	truncated bool
 }
 
 func (w *responseWriter) Write(data []byte) (int, error) {
 	n, err := w.ResponseWriter.Write(data)
+	w.capture(data[:n])
 	w.size += n
 	return n, err
 }
 
 func (w *responseWriter) WriteString(s string) (int, error) {
 	n, err := w.ResponseWriter.WriteString(s)
+	w.capture([]byte(s[:n]))
 	w.size += n
 	return n, err
 }
 
+// capture buffers written bytes up to the configured limit
+func (w *responseWriter) capture(data []byte) {
+	if w.body == nil {
+		return
+	}
+	remaining := w.limit - w.body.Len()
+	if len(data) > remaining {
+		data = data[:remaining]
+		w.truncated = true
+	}
+	w.body.Write(data)
+}
+
 // ginMiddleware creates the Gin middleware for request tracking
 func (d *DebugBar) ginMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !d.config.Enabled {
+		if !d.config.Enabled || d.config.ReadOnly {
+			c.Next()
+			return
+		}
+
+		// Skip the debug bar's own endpoints
+		if d.isDebugBarPath(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
 
-		// Skip the debug bar WebSocket endpoint
-		if c.Request.URL.Path == d.config.WebSocketPath {
+		// Leave requests without a valid activation token alone
+		activatedBy, ok := d.activate(c)
+		if !ok {
 			c.Next()
 			return
 		}
 
-		startTime := time.Now()
+		startTime := d.clock()
+
+		// Apply the capture rules before doing any work for the request
+		decision := d.captureDecision(c)
+		if !decision.capture {
+			c.Next()
+			d.captureSkipped(c, startTime, activatedBy)
+			return
+		}
 
 		// Create request info
 		reqInfo := &RequestInfo{
-			ID:          uuid.New().String(),
-			Method:      c.Request.Method,
-			Path:        c.Request.URL.Path,
-			StartTime:   startTime,
-			Headers:     make(map[string]string),
-			QueryParams: make(map[string]string),
-			Queries:     make([]QueryInfo, 0),
-			Errors:      make([]ErrorInfo, 0),
-			ClientIP:    c.ClientIP(),
+			ID:             uuid.New().String(),
+			Method:         c.Request.Method,
+			Path:           c.Request.URL.Path,
+			StartTime:      startTime,
+			RequestHeaders: d.redactor.RedactHeaders(headerFields(c.Request.Header)),
+			QueryValues:    d.redactor.RedactQuery(queryFields(c.Request.URL.RawQuery)),
+			Queries:        make([]QueryInfo, 0),
+			Errors:         make([]ErrorInfo, 0),
+			ClientIP:       c.ClientIP(),
+			ActivatedBy:    activatedBy,
 		}
 
-		// Capture headers
-		for key, values := range c.Request.Header {
-			if len(values) > 0 {
-				reqInfo.Headers[key] = values[0]
+		// Capture the matched route. Gin resolves the route before running
+		// the handler chain, so this is available up front.
+		if route := c.FullPath(); route != "" {
+			reqInfo.Route = route
+			reqInfo.HandlerName = c.HandlerName()
+			for _, param := range c.Params {
+				reqInfo.PathParams = append(reqInfo.PathParams, PathParam{
+					Key:   param.Key,
+					Value: d.redactor.RedactString(param.Value),
+				})
 			}
+		} else {
+			reqInfo.Unmatched = true
 		}
 
-		// Capture query parameters
-		for key, values := range c.Request.URL.Query() {
-			if len(values) > 0 {
-				reqInfo.QueryParams[key] = values[0]
-			}
+		// Keep the single-valued maps for older clients
+		if !d.config.MultiValueOnly {
+			reqInfo.Headers = reqInfo.RequestHeaders.Flatten()
+			reqInfo.QueryParams = reqInfo.QueryValues.Flatten()
 		}
 
-		// Capture request body if enabled
-		if d.config.CaptureRequestBody && c.Request.Body != nil {
-			bodyBytes, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(d.config.MaxBodySize)))
-			if err == nil {
-				reqInfo.RequestBody = string(bodyBytes)
-				// Restore the body for the actual handler
-				c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		// Capture request body if enabled. The handler still receives the
+		// complete body, the debug bar only keeps up to MaxBodySize bytes.
+		var body *bodyCapture
+		if decision.requestBody && d.config.MaxBodySize > 0 &&
+			c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body = captureBody(c.Request.Body, d.config.MaxBodySize)
+			c.Request.Body = body
+			reqInfo.RequestBody, reqInfo.RequestBodyFormat, reqInfo.RequestBodyTruncated = d.renderBody(
+				body.captured,
+				c.GetHeader("Content-Type"),
+				c.GetHeader("Content-Encoding"),
+				d.config.MaxBodySize,
+				body.truncated,
+			)
+			reqInfo.RequestBody = d.redactRenderedBody(c.ContentType(), reqInfo.RequestBodyFormat, reqInfo.RequestBody)
+
+			// Parse form bodies into fields and file metadata
+			reqInfo.Form = d.parseForm(c.GetHeader("Content-Type"), body.captured, body.truncated)
+			if reqInfo.Form != nil && c.ContentType() == "multipart/form-data" {
+				// The raw multipart body is boundaries and file data, the
+				// parsed form is all that's useful to show
+				reqInfo.RequestBody = ""
+				if body.truncated {
+					// Measure files past the captured prefix as the handler
+					// reads them
+					_, params, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+					body.parts = newPartSizer(params["boundary"])
+					defer body.parts.finish()
+				}
 			}
 		}
 
@@ -91,6 +155,10 @@ func (d *DebugBar) ginMiddleware() gin.HandlerFunc {
 
 		// Wrap response writer to capture size
 		rw := &responseWriter{ResponseWriter: c.Writer, size: 0}
+		if decision.responseBody && d.config.MaxResponseBodySize > 0 {
+			rw.body = &bytes.Buffer{}
+			rw.limit = d.config.MaxResponseBodySize
+		}
 		c.Writer = rw
 
 		// Broadcast request start
@@ -103,7 +171,7 @@ func (d *DebugBar) ginMiddleware() gin.HandlerFunc {
 		c.Next()
 
 		// Calculate final metrics
-		endTime := time.Now()
+		endTime := d.clock()
 		duration := endTime.Sub(startTime)
 
 		reqInfo.EndTime = endTime
@@ -111,6 +179,30 @@ func (d *DebugBar) ginMiddleware() gin.HandlerFunc {
 		reqInfo.DurationMs = float64(duration.Nanoseconds()) / 1e6
 		reqInfo.StatusCode = c.Writer.Status()
 		reqInfo.ResponseSize = rw.size
+		reqInfo.ResponseHeaders = d.redactor.RedactHeaders(headerFields(rw.Header()))
+
+		// Identify the user once auth middleware has run
+		if d.config.UserResolver != nil {
+			if user := d.config.UserResolver(c); user != nil {
+				user.Attributes = d.redactor.RedactValues(d.encoder.EncodeMap(user.Attributes))
+				reqInfo.User = user
+			}
+		}
+
+		// Gather extra data such as context keys and validation errors
+		d.runCollectors(c, reqInfo)
+
+		if body != nil {
+			reqInfo.RequestBodySize = body.totalSize(c.Request.ContentLength)
+			if body.parts != nil {
+				d.mergeStreamedFiles(reqInfo.Form, body.parts.finish())
+			}
+		}
+
+		// Capture response body if enabled
+		if rw.body != nil {
+			d.formatResponseBody(rw, reqInfo)
+		}
 
 		// Capture memory usage
 		var memStats runtime.MemStats
@@ -0,0 +1,228 @@
+package godebugbar
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testConfig returns a configuration for tests that captures everything
+// and keeps redaction and lint rules out of the way
+func testConfig() Config {
+	config := DefaultConfig()
+	config.Redaction = &RedactionConfig{}
+	config.LintRules = nil
+	config.Auth = AllowAll()
+	return config
+}
+
+// testEngine returns a Gin engine running the debug bar middleware and
+// closes the debug bar when the test ends
+func testEngine(t *testing.T, d *DebugBar) *gin.Engine {
+	t.Helper()
+	t.Cleanup(func() { d.Close() })
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(d.Middleware())
+	return engine
+}
+
+// serve runs a request through the engine and returns the captured
+// request, which is nil when nothing was captured
+func serve(t *testing.T, d *DebugBar, engine *gin.Engine, req *http.Request) (*httptest.ResponseRecorder, *RequestInfo) {
+	t.Helper()
+	before := len(d.GetHistory())
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	history := d.GetHistory()
+	if len(history) == before {
+		return w, nil
+	}
+	return w, history[len(history)-1]
+}
+
+func TestRequestBodyCapture(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		maxBodySize int
+		handlerRead bool
+		captured    string
+		truncated   bool
+		size        int64
+	}{
+		{"small body", `{"name":"ada"}`, 1024, true, `{"name":"ada"}`, false, 14},
+		{"truncated body", strings.Repeat("a", 100), 10, true, strings.Repeat("a", 10), true, 100},
+		{"body the handler ignores", strings.Repeat("a", 100), 10, false, strings.Repeat("a", 10), true, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxBodySize = tt.maxBodySize
+			d := New(config)
+			engine := testEngine(t, d)
+
+			var received string
+			engine.POST("/echo", func(c *gin.Context) {
+				if tt.handlerRead {
+					data, _ := io.ReadAll(c.Request.Body)
+					received = string(data)
+				}
+				c.Status(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "text/plain")
+			_, captured := serve(t, d, engine, req)
+			if captured == nil {
+				t.Fatal("request wasn't captured")
+			}
+
+			if tt.handlerRead && received != tt.body {
+				t.Errorf("handler read %d bytes, want the complete %d byte body", len(received), len(tt.body))
+			}
+			if captured.RequestBody != tt.captured || captured.RequestBodyTruncated != tt.truncated {
+				t.Errorf("captured %q (truncated %v), want %q (truncated %v)",
+					captured.RequestBody, captured.RequestBodyTruncated, tt.captured, tt.truncated)
+			}
+			if captured.RequestBodySize != tt.size {
+				t.Errorf("RequestBodySize = %d, want %d", captured.RequestBodySize, tt.size)
+			}
+		})
+	}
+}
+
+func TestRequestBodyCaptureDisabled(t *testing.T) {
+	config := testConfig()
+	config.CaptureRequestBody = false
+	d := New(config)
+	engine := testEngine(t, d)
+
+	var received string
+	engine.POST("/echo", func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		received = string(data)
+	})
+
+	_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("secret")))
+	if received != "secret" {
+		t.Errorf("handler read %q, want the body", received)
+	}
+	if captured == nil || captured.RequestBody != "" || captured.RequestBodySize != 0 {
+		t.Errorf("captured %+v, want no body", captured)
+	}
+}
+
+func TestResponseBodyCapture(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int
+		contentType string
+		body        string
+		captured    string
+		format      string
+		truncated   bool
+	}{
+		{"json", 1024, "application/json", `{"id":1}`, `{"id":1}`, BodyFormatText, false},
+		{"truncated", 4, "text/plain", "hello world", "hell", BodyFormatText, true},
+		{"binary", 1024, "application/octet-stream", "\x00\x01\x02", "00000000  00 01 02                                          |...|\n", BodyFormatHex, false},
+		{"disabled by size", 0, "text/plain", "hello", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxResponseBodySize = tt.maxSize
+			d := New(config)
+			engine := testEngine(t, d)
+			engine.GET("/body", func(c *gin.Context) {
+				c.Data(http.StatusOK, tt.contentType, []byte(tt.body))
+			})
+
+			w, captured := serve(t, d, engine, httptest.NewRequest(http.MethodGet, "/body", nil))
+			if w.Body.String() != tt.body {
+				t.Errorf("client got %q, want the complete response", w.Body.String())
+			}
+			if captured == nil {
+				t.Fatal("request wasn't captured")
+			}
+			if captured.ResponseBody != tt.captured || captured.ResponseBodyFormat != tt.format || captured.ResponseBodyTruncated != tt.truncated {
+				t.Errorf("captured %q as %q (truncated %v), want %q as %q (truncated %v)",
+					captured.ResponseBody, captured.ResponseBodyFormat, captured.ResponseBodyTruncated,
+					tt.captured, tt.format, tt.truncated)
+			}
+			if captured.ResponseSize != len(tt.body) || captured.StatusCode != http.StatusOK {
+				t.Errorf("ResponseSize = %d, StatusCode = %d, want %d and 200", captured.ResponseSize, captured.StatusCode, len(tt.body))
+			}
+		})
+	}
+}
+
+func TestResponseWriteString(t *testing.T) {
+	d := New(testConfig())
+	engine := testEngine(t, d)
+	engine.GET("/string", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.String(http.StatusCreated, "created %d", 1)
+	})
+
+	_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodGet, "/string", nil))
+	if captured == nil || captured.ResponseBody != "created 1" || captured.ResponseContentType != "text/plain" || captured.StatusCode != http.StatusCreated {
+		t.Errorf("captured %+v, want the string response", captured)
+	}
+}
+
+func TestRouteCapture(t *testing.T) {
+	config := testConfig()
+	config.Redaction = &RedactionConfig{Patterns: DefaultRedactionConfig().Patterns}
+	d := New(config)
+	engine := testEngine(t, d)
+	engine.GET("/users/:id/files/*path", testRouteHandler)
+
+	tests := []struct {
+		path    string
+		route   string
+		params  []PathParam
+		handler string
+	}{
+		{
+			"/users/42/files/a/b.txt",
+			"/users/:id/files/*path",
+			[]PathParam{{Key: "id", Value: "42"}, {Key: "path", Value: "/a/b.txt"}},
+			"github.com/pitchinnate/godebugbar/server.testRouteHandler",
+		},
+		{
+			"/users/4111111111111111/files/x",
+			"/users/:id/files/*path",
+			[]PathParam{{Key: "id", Value: DefaultRedactionReplacement}, {Key: "path", Value: "/x"}},
+			"github.com/pitchinnate/godebugbar/server.testRouteHandler",
+		},
+		{"/missing", "", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if captured == nil {
+				t.Fatal("request wasn't captured")
+			}
+			if captured.Route != tt.route || captured.HandlerName != tt.handler || captured.Unmatched != (tt.route == "") {
+				t.Errorf("route %q, handler %q, unmatched %v", captured.Route, captured.HandlerName, captured.Unmatched)
+			}
+			if !reflect.DeepEqual(captured.PathParams, tt.params) {
+				t.Errorf("PathParams = %v, want %v", captured.PathParams, tt.params)
+			}
+		})
+	}
+}
+
+func testRouteHandler(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,176 @@
+package godebugbar
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Logger receives the debug bar's own diagnostic messages. *log.Logger
+// satisfies it.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// Option configures a DebugBar created with NewWithOptions
+type Option func(*options) error
+
+// options collects the settings passed to NewWithOptions
+type options struct {
+	config   Config
+	store    Store
+	redactor *Redactor
+	clock    func() time.Time
+	logger   Logger
+}
+
+// WithConfig replaces the configuration options start from, which is
+// DefaultConfig() otherwise. Apply it before options that adjust the
+// configuration.
+func WithConfig(config Config) Option {
+	return func(o *options) error {
+		o.config = config
+		return nil
+	}
+}
+
+// WithStore stores requests in store instead of a new in-memory store sized
+// by Config.MaxRequests. A RequestStore must hold at least MaxRequests.
+func WithStore(store Store) Option {
+	return func(o *options) error {
+		if store == nil {
+			return errors.New("WithStore: store is nil")
+		}
+		o.store = store
+		return nil
+	}
+}
+
+// WithRedactor uses redactor as-is instead of building one from
+// Config.Redaction
+func WithRedactor(redactor *Redactor) Option {
+	return func(o *options) error {
+		if redactor == nil {
+			return errors.New("WithRedactor: redactor is nil")
+		}
+		o.redactor = redactor
+		return nil
+	}
+}
+
+// WithCollectors replaces the collectors run for each request
+func WithCollectors(collectors ...Collector) Option {
+	return func(o *options) error {
+		for _, collector := range collectors {
+			if collector == nil {
+				return errors.New("WithCollectors: collector is nil")
+			}
+		}
+		o.config.Collectors = collectors
+		return nil
+	}
+}
+
+// WithAuth authenticates clients of the debug bar endpoints with auth
+func WithAuth(auth Authenticator) Option {
+	return func(o *options) error {
+		if auth == nil {
+			return errors.New("WithAuth: authenticator is nil, use AllowAll() to accept every client")
+		}
+		o.config.Auth = auth
+		return nil
+	}
+}
+
+// WithActivation only captures requests carrying a token signed with secret
+func WithActivation(secret []byte) Option {
+	return func(o *options) error {
+		if len(secret) == 0 {
+			return errors.New("WithActivation: secret is empty")
+		}
+		o.config.Activation = &ActivationConfig{Secret: secret}
+		return nil
+	}
+}
+
+// WithClock sets the source of the current time used for request,
+// query and error timestamps
+func WithClock(now func() time.Time) Option {
+	return func(o *options) error {
+		if now == nil {
+			return errors.New("WithClock: clock is nil")
+		}
+		o.clock = now
+		return nil
+	}
+}
+
+// WithLogger sends the debug bar's diagnostic messages to logger instead of
+// the standard logger
+func WithLogger(logger Logger) Option {
+	return func(o *options) error {
+		if logger == nil {
+			return errors.New("WithLogger: logger is nil")
+		}
+		o.logger = logger
+		return nil
+	}
+}
+
+// NewWithOptions creates a DebugBar from DefaultConfig() adjusted by opts.
+// Unlike New it validates the result and returns an error for invalid
+// settings.
+func NewWithOptions(opts ...Option) (*DebugBar, error) {
+	o := &options{config: DefaultConfig()}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return newDebugBar(o), nil
+}
+
+// validate checks the configuration together with the other options
+func (o *options) validate() error {
+	config := o.config
+	if o.store != nil && config.MaxRequests <= 0 {
+		// A custom store has its own capacity
+		config.MaxRequests = 1
+	}
+
+	var errs ConfigErrors
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err.(ConfigErrors)...)
+	}
+	if store, ok := o.store.(*RequestStore); ok {
+		switch {
+		case store.maxSize <= 0:
+			errs = append(errs, ConfigError{Field: "Store", Message: "must have a capacity greater than 0"})
+		case store.maxSize < config.MaxRequests:
+			// The store would silently drop requests retention still
+			// keeps, including pinned ones
+			errs = append(errs, ConfigError{Field: "Store", Message: fmt.Sprintf(
+				"holds %d requests, fewer than MaxRequests (%d)", store.maxSize, config.MaxRequests)})
+		}
+	}
+	if o.redactor != nil && config.Redaction != nil {
+		errs = append(errs, ConfigError{Field: "Redaction", Message: "can't be combined with WithRedactor"})
+	}
+	if o.redactor != nil && config.Activation != nil {
+		// Activation tokens are only masked by redactors built from config
+		errs = append(errs, ConfigError{Field: "Activation", Message: "can't be combined with WithRedactor"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// defaultLogger is used when no Logger is configured
+var defaultLogger Logger = log.Default()
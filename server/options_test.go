@@ -0,0 +1,127 @@
+package godebugbar
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	store := NewRequestStore(DefaultConfig().MaxRequests)
+	redactor := NewRedactor(RedactionConfig{})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logger := log.New(io.Discard, "", 0)
+
+	d, err := NewWithOptions(
+		WithStore(store),
+		WithRedactor(redactor),
+		WithCollectors(NewCookieCollector()),
+		WithAuth(AllowAll()),
+		WithClock(func() time.Time { return now }),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if d.store != store || d.redactor != redactor || d.logger != logger || len(d.collectors) != 1 {
+		t.Errorf("options weren't applied: %+v", d)
+	}
+	if !d.clock().Equal(now) {
+		t.Errorf("clock() = %v, want the injected clock", d.clock())
+	}
+	if !d.config.Enabled || d.config.WebSocketPath != DefaultConfig().WebSocketPath {
+		t.Errorf("config = %+v, want DefaultConfig()", d.config)
+	}
+
+	d, err = NewWithOptions(WithActivation([]byte("secret")), WithConfig(testConfig()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.config.Activation != nil {
+		t.Error("WithConfig didn't replace the configuration set before it")
+	}
+}
+
+func TestNewWithOptionsNil(t *testing.T) {
+	tests := []struct {
+		name   string
+		option Option
+	}{
+		{"store", WithStore(nil)},
+		{"redactor", WithRedactor(nil)},
+		{"collector", WithCollectors(NewCookieCollector(), nil)},
+		{"auth", WithAuth(nil)},
+		{"activation", WithActivation(nil)},
+		{"clock", WithClock(nil)},
+		{"logger", WithLogger(nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d, err := NewWithOptions(tt.option); err == nil || d != nil {
+				t.Errorf("NewWithOptions() = %v, %v, want an error", d, err)
+			}
+		})
+	}
+}
+
+func TestNewWithOptionsValidation(t *testing.T) {
+	invalid := testConfig()
+	invalid.MaxRequests = 0
+	invalid.WebSocketPath = "ws"
+	invalid.BinaryPreview = "octal"
+
+	noCapacity := testConfig()
+	noCapacity.MaxRequests = 0
+
+	withActivation := testConfig()
+	withActivation.Redaction = nil
+	withActivation.Activation = &ActivationConfig{Secret: []byte("secret")}
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		fields []string
+	}{
+		{"config fields", []Option{WithConfig(invalid)}, []string{"MaxRequests", "WebSocketPath", "BinaryPreview"}},
+		{"custom store sets the capacity", []Option{WithConfig(noCapacity), WithStore(NewRequestStore(5))}, nil},
+		{"empty store", []Option{WithStore(NewRequestStore(0))}, []string{"Store"}},
+		{"store smaller than MaxRequests", []Option{WithStore(NewRequestStore(10))}, []string{"Store"}},
+		{"store larger than MaxRequests", []Option{WithStore(NewRequestStore(500))}, nil},
+		{"redactor and redaction", []Option{WithConfig(testConfig()), WithRedactor(NewRedactor(RedactionConfig{}))}, []string{"Redaction"}},
+		{"redactor and activation", []Option{WithConfig(withActivation), WithRedactor(NewRedactor(RedactionConfig{}))}, []string{"Activation"}},
+		{"sample rate", []Option{WithConfig(Config{
+			Enabled:       true,
+			MaxRequests:   10,
+			WebSocketPath: "/ws",
+			CaptureRules:  []CaptureRule{{SampleRate: 1.5}},
+		})}, []string{"CaptureRules[0].SampleRate"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewWithOptions(tt.opts...)
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("NewWithOptions() error = %v", err)
+				}
+				return
+			}
+
+			var errs ConfigErrors
+			if !errors.As(err, &errs) || d != nil {
+				t.Fatalf("NewWithOptions() = %v, %v, want ConfigErrors", d, err)
+			}
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("errors = %v, want fields %v", errs, tt.fields)
+			}
+			for i, field := range tt.fields {
+				if errs[i].Field != field {
+					t.Errorf("errors[%d] = %v, want field %s", i, errs[i], field)
+				}
+			}
+		})
+	}
+}
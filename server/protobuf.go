@@ -0,0 +1,122 @@
+package godebugbar
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufDecoder renders protobuf bodies as JSON. It handles plain
+// protobuf-over-HTTP bodies and length-prefixed gRPC-web frames.
+type ProtobufDecoder struct {
+	descriptor protoreflect.MessageDescriptor
+	types      *protoregistry.Types
+}
+
+// NewProtobufDecoder creates a decoder for messages of the given type. When
+// desc is nil, the message type is taken from the "proto" or "messageType"
+// content type parameter and looked up in the global registry.
+func NewProtobufDecoder(desc protoreflect.MessageDescriptor) *ProtobufDecoder {
+	return &ProtobufDecoder{
+		descriptor: desc,
+		types:      protoregistry.GlobalTypes,
+	}
+}
+
+// Decode implements BodyDecoder, joining the messages of a gRPC-web body
+// with newlines
+func (p *ProtobufDecoder) Decode(contentType string, body []byte) (string, error) {
+	messages, err := p.DecodeMessages(contentType, body)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(messages, "\n"), nil
+}
+
+// DecodeMessages implements MessageDecoder. A gRPC-web body yields one
+// entry per frame, trailers included; other bodies hold a single message.
+func (p *ProtobufDecoder) DecodeMessages(contentType string, body []byte) ([]string, error) {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+
+	desc, err := p.resolve(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(mediaType, "application/grpc-web") {
+		text, err := p.format(desc, body)
+		if err != nil {
+			return nil, err
+		}
+		return []string{text}, nil
+	}
+
+	// gRPC-web bodies are a sequence of frames: a flag byte, a big-endian
+	// length and the message. Trailer frames have the high flag bit set.
+	var messages []string
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, errors.New("truncated gRPC-web frame header")
+		}
+		flag := body[0]
+		length := binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < length {
+			return nil, errors.New("truncated gRPC-web frame")
+		}
+		frame := body[5 : 5+length]
+		body = body[5+length:]
+
+		if flag&0x80 != 0 {
+			messages = append(messages, strings.TrimSpace(string(frame)))
+			continue
+		}
+		if flag&0x01 != 0 {
+			return nil, errors.New("compressed gRPC-web frames are not supported")
+		}
+
+		text, err := p.format(desc, frame)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, text)
+	}
+	return messages, nil
+}
+
+// resolve returns the message descriptor to decode with
+func (p *ProtobufDecoder) resolve(params map[string]string) (protoreflect.MessageDescriptor, error) {
+	if p.descriptor != nil {
+		return p.descriptor, nil
+	}
+
+	name := params["proto"]
+	if name == "" {
+		name = params["messagetype"]
+	}
+	if name == "" {
+		return nil, errors.New("no protobuf message type for body")
+	}
+
+	messageType, err := p.types.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("unknown protobuf message type %q: %w", name, err)
+	}
+	return messageType.Descriptor(), nil
+}
+
+// format unmarshals a single message and renders it as indented JSON
+func (p *ProtobufDecoder) format(desc protoreflect.MessageDescriptor, data []byte) (string, error) {
+	message := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(data, message); err != nil {
+		return "", err
+	}
+	return protojson.MarshalOptions{Multiline: true}.Format(message), nil
+}
@@ -0,0 +1,491 @@
+package godebugbar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultRedactionReplacement is the value used in place of redacted data
+const DefaultRedactionReplacement = "[REDACTED]"
+
+// RedactionConfig controls which captured values are masked before a
+// request is stored or broadcast. Name matching is case-insensitive.
+type RedactionConfig struct {
+	// Replacement is the string used in place of redacted values
+	Replacement string
+
+	// Headers are request and response header names whose values are masked
+	Headers []string
+
+	// QueryParams are query parameter names whose values are masked
+	QueryParams []string
+
+	// BodyPaths are JSON body paths whose values are masked. A bare name
+	// such as "password" matches that key at any depth, a dotted path such
+	// as "user.password" matches from the root and "*" matches any key or
+	// array index.
+	BodyPaths []string
+
+	// FormFields are form field names whose values are masked
+	FormFields []string
+
+	// Cookies are cookie names whose values are masked in the captured
+	// cookie lists. When empty, every cookie value is masked while the
+	// Cookie or Set-Cookie header is redacted.
+	Cookies []string
+
+	// SQLColumns are column names whose bound query args are masked
+	SQLColumns []string
+
+	// Patterns are masked wherever they appear in captured text, including
+	// bodies, SQL, query args and error messages
+	Patterns []*regexp.Regexp
+}
+
+// sensitiveNames are field names that hold secrets in most applications
+var sensitiveNames = []string{
+	"password", "passwd", "pwd", "secret", "client_secret", "token",
+	"access_token", "refresh_token", "id_token", "api_key", "apikey",
+	"private_key", "credit_card", "card_number", "cvv", "cvc", "ssn",
+}
+
+// DefaultRedactionConfig returns a configuration that masks common
+// credentials, card numbers and JWTs
+func DefaultRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		Replacement: DefaultRedactionReplacement,
+		Headers: []string{
+			"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+			"X-Api-Key", "X-Auth-Token", "X-Csrf-Token", "X-Xsrf-Token",
+		},
+		QueryParams: append([]string{"key", "signature", "sig", "code"}, sensitiveNames...),
+		BodyPaths:   append([]string(nil), sensitiveNames...),
+		FormFields:  append([]string(nil), sensitiveNames...),
+		Cookies: append([]string{
+			"session", "sessionid", "session_id", "sid", "connect.sid", "phpsessid",
+			"jsessionid", "laravel_session", "remember_token", "csrf_token", "_csrf",
+			"xsrf-token",
+		}, sensitiveNames...),
+		SQLColumns: append([]string{"password_hash", "encrypted_password", "remember_token"}, sensitiveNames...),
+		Patterns: []*regexp.Regexp{
+			// Visa, Mastercard and Discover card numbers
+			regexp.MustCompile(`\b(?:4\d{3}|5[1-5]\d{2}|2[2-7]\d{2}|6(?:011|5\d{2}))(?:[ -]?\d{4}){3}\b`),
+			// American Express card numbers
+			regexp.MustCompile(`\b3[47]\d{2}[ -]?\d{6}[ -]?\d{5}\b`),
+			// JSON Web Tokens
+			regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		},
+	}
+}
+
+// Redactor masks sensitive values in captured request data
+type Redactor struct {
+	replacement string
+	headers     map[string]bool
+	queryParams map[string]bool
+	bodyNames   map[string]bool
+	bodyPaths   [][]string
+	formFields  map[string]bool
+	cookies     map[string]bool
+	sqlColumns  map[string]bool
+	patterns    []*regexp.Regexp
+	keyPattern  *regexp.Regexp
+}
+
+// NewRedactor creates a Redactor from the given configuration
+func NewRedactor(config RedactionConfig) *Redactor {
+	r := &Redactor{
+		replacement: config.Replacement,
+		headers:     nameSet(config.Headers),
+		queryParams: nameSet(config.QueryParams),
+		bodyNames:   make(map[string]bool),
+		formFields:  nameSet(config.FormFields),
+		cookies:     nameSet(config.Cookies),
+		sqlColumns:  nameSet(config.SQLColumns),
+		patterns:    config.Patterns,
+	}
+	if r.replacement == "" {
+		r.replacement = DefaultRedactionReplacement
+	}
+
+	var keys []string
+	for _, path := range config.BodyPaths {
+		parts := strings.Split(strings.ToLower(path), ".")
+		if len(parts) == 1 {
+			r.bodyNames[parts[0]] = true
+		} else {
+			r.bodyPaths = append(r.bodyPaths, parts)
+		}
+		if last := parts[len(parts)-1]; last != "*" {
+			keys = append(keys, regexp.QuoteMeta(last))
+		}
+	}
+
+	// Fallback for JSON bodies that can't be parsed, e.g. truncated ones
+	if len(keys) > 0 {
+		r.keyPattern = regexp.MustCompile(`(?i)("(?:` + strings.Join(keys, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"|[^,}\]\s]+)`)
+	}
+
+	return r
+}
+
+// redactFields masks the values of fields whose names are in the given set
+// and applies the patterns to the rest
+func (r *Redactor) redactFields(fields Fields, names map[string]bool) Fields {
+	for i, field := range fields {
+		if names[strings.ToLower(field.Name)] {
+			values := make([]string, len(field.Values))
+			for j := range values {
+				values[j] = r.replacement
+			}
+			fields[i].Values = values
+			continue
+		}
+		for j, value := range field.Values {
+			fields[i].Values[j] = r.RedactString(value)
+		}
+	}
+	return fields
+}
+
+// RedactHeaders masks sensitive header values
+func (r *Redactor) RedactHeaders(fields Fields) Fields {
+	return r.redactFields(fields, r.headers)
+}
+
+// RedactQuery masks sensitive query parameter values
+func (r *Redactor) RedactQuery(fields Fields) Fields {
+	return r.redactFields(fields, r.queryParams)
+}
+
+// RedactString masks every match of the configured patterns
+func (r *Redactor) RedactString(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
+
+// RedactBody masks sensitive values in a request or response body based on
+// its content type
+func (r *Redactor) RedactBody(contentType, body string) string {
+	if body == "" {
+		return body
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || mediaType == "application/x-ndjson" || strings.HasSuffix(mediaType, "+json"):
+		body = r.redactJSON(body)
+	case mediaType == "application/x-www-form-urlencoded":
+		body = r.redactForm(body)
+	}
+	return r.RedactString(body)
+}
+
+// RedactArgs masks query args bound to sensitive columns and any args
+// matching the configured patterns
+func (r *Redactor) RedactArgs(sql string, args []any) []any {
+	if len(args) == 0 {
+		return args
+	}
+
+	result := make([]any, len(args))
+	copy(result, args)
+
+	for i, column := range placeholderColumns(sql, len(args)) {
+		if column != "" && r.sqlColumns[strings.ToLower(column)] {
+			result[i] = r.replacement
+		}
+	}
+	for i, arg := range result {
+		if s, ok := arg.(string); ok {
+			result[i] = r.RedactString(s)
+		}
+	}
+	return result
+}
+
+// RedactFormValue masks a form field value if the field is sensitive
+func (r *Redactor) RedactFormValue(name, value string) string {
+	if r.formFields[strings.ToLower(name)] {
+		return r.replacement
+	}
+	return r.RedactString(value)
+}
+
+// RedactCookie masks a cookie value when the cookie name is sensitive.
+// Without configured cookie names, every cookie carried by a sensitive
+// header, Cookie or Set-Cookie, is masked.
+func (r *Redactor) RedactCookie(header, name, value string) string {
+	if r.cookies[strings.ToLower(name)] || r.IsSensitiveName(name) {
+		return r.replacement
+	}
+	if len(r.cookies) == 0 && r.headers[strings.ToLower(header)] {
+		return r.replacement
+	}
+	return r.RedactString(value)
+}
+
+// RedactValues masks entries of an encoded value tree whose keys match the
+// configured body paths, and pattern matches inside its strings
+func (r *Redactor) RedactValues(values map[string]any) map[string]any {
+	if values == nil {
+		return nil
+	}
+	return r.RedactValue(values).(map[string]any)
+}
+
+// RedactValue masks a value produced by a ValueEncoder like RedactValues
+func (r *Redactor) RedactValue(value any) any {
+	changed := false
+	value = r.redactJSONValue(value, nil, &changed)
+	return r.redactStrings(value)
+}
+
+// redactStrings applies the patterns to every string in a value tree
+func (r *Redactor) redactStrings(value any) any {
+	switch v := value.(type) {
+	case string:
+		return r.RedactString(v)
+	case json.RawMessage:
+		// Output of a json.Marshaler, which masking must keep valid
+		redacted := r.RedactString(r.redactJSON(string(v)))
+		if !json.Valid([]byte(redacted)) {
+			return r.replacement
+		}
+		return json.RawMessage(redacted)
+	case map[string]any:
+		for key, child := range v {
+			v[key] = r.redactStrings(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = r.redactStrings(child)
+		}
+	}
+	return value
+}
+
+// IsSensitiveName reports whether a field name is configured as a sensitive
+// body key or form field
+func (r *Redactor) IsSensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	return r.bodyNames[name] || r.formFields[name]
+}
+
+// redactJSON masks sensitive keys in every JSON value of a body, so NDJSON
+// and other concatenated documents are covered, keeping the original text
+// of values that need no masking
+func (r *Redactor) redactJSON(body string) string {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var out strings.Builder
+	offset := 0
+	for {
+		var value any
+		err := decoder.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Truncated or invalid JSON, mask the rest by key name instead
+			rest := body[offset:]
+			if r.keyPattern != nil {
+				rest = r.keyPattern.ReplaceAllString(rest, `${1}"`+r.replacement+`"`)
+			}
+			out.WriteString(rest)
+			return out.String()
+		}
+
+		end := int(decoder.InputOffset())
+		raw := body[offset:end]
+		offset = end
+
+		changed := false
+		value = r.redactJSONValue(value, nil, &changed)
+		if !changed {
+			// Keep the original formatting when nothing was masked
+			out.WriteString(raw)
+			continue
+		}
+
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err != nil {
+			return body
+		}
+		// Keep the whitespace separating the value from the previous one
+		trimmed := strings.TrimLeft(raw, " \t\r\n")
+		out.WriteString(raw[:len(raw)-len(trimmed)])
+		out.WriteString(strings.TrimSuffix(buf.String(), "\n"))
+	}
+	out.WriteString(body[offset:])
+	return out.String()
+}
+
+func (r *Redactor) redactJSONValue(value any, path []string, changed *bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			childPath := append(path[:len(path):len(path)], strings.ToLower(key))
+			if r.matchesBodyPath(childPath) {
+				v[key] = r.replacement
+				*changed = true
+				continue
+			}
+			v[key] = r.redactJSONValue(child, childPath, changed)
+		}
+	case []any:
+		for i, child := range v {
+			childPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if r.matchesBodyPath(childPath) {
+				v[i] = r.replacement
+				*changed = true
+				continue
+			}
+			v[i] = r.redactJSONValue(child, childPath, changed)
+		}
+	}
+	return value
+}
+
+func (r *Redactor) matchesBodyPath(path []string) bool {
+	if r.bodyNames[path[len(path)-1]] {
+		return true
+	}
+	for _, pattern := range r.bodyPaths {
+		if len(pattern) != len(path) {
+			continue
+		}
+		matched := true
+		for i, part := range pattern {
+			if part != "*" && part != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) redactForm(body string) string {
+	fields := queryFields(body)
+
+	sensitive := false
+	for _, field := range fields {
+		if r.formFields[strings.ToLower(field.Name)] {
+			sensitive = true
+			break
+		}
+	}
+	if !sensitive {
+		// Keep the original encoding when nothing needs masking
+		return body
+	}
+
+	values := make([]string, 0, len(fields))
+	for _, field := range fields {
+		masked := r.formFields[strings.ToLower(field.Name)]
+		for _, value := range field.Values {
+			// Leave the replacement unescaped so it stays readable
+			encoded := r.replacement
+			if !masked {
+				encoded = url.QueryEscape(value)
+			}
+			values = append(values, url.QueryEscape(field.Name)+"="+encoded)
+		}
+	}
+	return strings.Join(values, "&")
+}
+
+var (
+	insertColumnsPattern = regexp.MustCompile("(?is)^\\s*insert\\s+into\\s+[^(]+\\(([^)]*)\\)\\s*values\\s*")
+	comparisonPattern    = regexp.MustCompile("(?i)([A-Za-z_][\\w]*)[`\"\\]]?\\s*(?:=|<>|!=|<=|>=|<|>|\\blike|\\bin\\s*\\((?:\\s*(?:\\?|\\$\\d+)\\s*,)*)\\s*$")
+	placeholderPattern   = regexp.MustCompile(`\?|\$\d+`)
+)
+
+// placeholderColumns works out which column each query arg is bound to,
+// returning an empty name where the column can't be determined
+func placeholderColumns(sql string, argCount int) []string {
+	columns := make([]string, argCount)
+	stripped := stripSQLLiterals(sql)
+
+	// INSERT INTO t (a, b) VALUES (?, ?), (?, ?) binds args by position
+	if match := insertColumnsPattern.FindStringSubmatchIndex(stripped); match != nil && match[3] > match[2] {
+		var names []string
+		for _, name := range strings.Split(stripped[match[2]:match[3]], ",") {
+			names = append(names, strings.Trim(strings.TrimSpace(name), "`\"[]"))
+		}
+
+		position := 0
+		for _, loc := range placeholderPattern.FindAllStringIndex(stripped[match[1]:], -1) {
+			if index := placeholderIndex(stripped[match[1]+loc[0]:], position); index >= 0 && index < argCount {
+				columns[index] = names[position%len(names)]
+			}
+			position++
+		}
+		return columns
+	}
+
+	position := 0
+	for _, loc := range placeholderPattern.FindAllStringIndex(stripped, -1) {
+		index := placeholderIndex(stripped[loc[0]:], position)
+		position++
+		if index < 0 || index >= argCount {
+			continue
+		}
+		if match := comparisonPattern.FindStringSubmatch(stripped[:loc[0]]); match != nil {
+			columns[index] = match[1]
+		}
+	}
+	return columns
+}
+
+// placeholderIndex returns the arg index for the placeholder at the start of
+// s: the number of a $n placeholder, or the running position for ?
+func placeholderIndex(s string, position int) int {
+	if s[0] != '$' {
+		return position
+	}
+	end := 1
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, err := strconv.Atoi(s[1:end])
+	if err != nil || n < 1 {
+		return -1
+	}
+	return n - 1
+}
+
+// masksAllCookies reports whether a configuration masks every cookie value,
+// which is the case without cookie names when both cookie headers are
+// redacted
+func masksAllCookies(config RedactionConfig) bool {
+	if len(config.Cookies) > 0 {
+		return false
+	}
+	headers := nameSet(config.Headers)
+	return headers["cookie"] && headers["set-cookie"]
+}
+
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
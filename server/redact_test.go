@@ -0,0 +1,155 @@
+package godebugbar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	r := NewRedactor(DefaultRedactionConfig())
+	mask := DefaultRedactionReplacement
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"empty", "application/json", "", ""},
+		{"json", "application/json", `{"user":"ada","password":"hunter2"}`, `{"password":"` + mask + `","user":"ada"}`},
+		{"nested json", "application/json", `{"user":{"Token":"abc"}}`, `{"user":{"Token":"` + mask + `"}}`},
+		{"json suffix", "application/vnd.api+json; charset=utf-8", `{"secret":1}`, `{"secret":"` + mask + `"}`},
+		{"json unchanged", "application/json", `{ "user": "ada" }`, `{ "user": "ada" }`},
+		{"truncated json", "application/json", `{"password": "hunter2", "user": "ad`, `{"password": "` + mask + `", "user": "ad`},
+		{"form", "application/x-www-form-urlencoded", "user=ada&password=hunter2", "user=ada&password=" + mask},
+		{"form unchanged", "application/x-www-form-urlencoded", "user=ada+l", "user=ada+l"},
+		{"card number", "text/plain", "paid with 4111 1111 1111 1111 today", "paid with " + mask + " today"},
+		{"jwt", "text/plain", "Bearer eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln", "Bearer " + mask},
+		{"plain text names", "text/plain", "password=hunter2", "password=hunter2"},
+		{
+			"every json value",
+			"application/json",
+			`{"name":"a"}` + "\n" + `{"password":"hunter2"}`,
+			`{"name":"a"}` + "\n" + `{"password":"` + mask + `"}`,
+		},
+		{
+			"values after a masked one are kept",
+			"application/json",
+			`{"password":"a"} {"name":"b"} [1, 2]` + "\n",
+			`{"password":"` + mask + `"} {"name":"b"} [1, 2]` + "\n",
+		},
+		{
+			"ndjson",
+			"application/x-ndjson",
+			`{"token":"a"}` + "\n" + `{"user":"b"}` + "\n" + `{"secret":"c"}` + "\n",
+			`{"token":"` + mask + `"}` + "\n" + `{"user":"b"}` + "\n" + `{"secret":"` + mask + `"}` + "\n",
+		},
+		{
+			"truncated after a complete value",
+			"application/x-ndjson",
+			`{"user":"a"}` + "\n" + `{"password": "hunter2", "us`,
+			`{"user":"a"}` + "\n" + `{"password": "` + mask + `", "us`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.RedactBody(tt.contentType, tt.body); got != tt.want {
+				t.Errorf("RedactBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactValues(t *testing.T) {
+	r := NewRedactor(RedactionConfig{
+		BodyPaths: []string{"password", "account.*.pin"},
+		Patterns:  DefaultRedactionConfig().Patterns,
+	})
+	mask := DefaultRedactionReplacement
+
+	values := map[string]any{
+		"Password": "hunter2",
+		"account": map[string]any{
+			"primary": map[string]any{"pin": "1234", "name": "checking"},
+		},
+		"cards": []any{"4111111111111111", "none"},
+		"pin":   "5678",
+	}
+	want := map[string]any{
+		"Password": mask,
+		"account": map[string]any{
+			"primary": map[string]any{"pin": mask, "name": "checking"},
+		},
+		"cards": []any{mask, "none"},
+		"pin":   "5678",
+	}
+
+	if got := r.RedactValues(values); !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactValues() = %v, want %v", got, want)
+	}
+	if got := r.RedactValues(nil); got != nil {
+		t.Errorf("RedactValues(nil) = %v, want nil", got)
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	r := NewRedactor(DefaultRedactionConfig())
+	mask := DefaultRedactionReplacement
+
+	tests := []struct {
+		sql  string
+		args []any
+		want []any
+	}{
+		{"SELECT * FROM users WHERE email = ? AND password = ?", []any{"ada@example.com", "hunter2"}, []any{"ada@example.com", mask}},
+		{"INSERT INTO users (name, token) VALUES ($1, $2)", []any{"ada", "abc"}, []any{"ada", mask}},
+		{"SELECT * FROM users WHERE id = ?", []any{42}, []any{42}},
+		{"SELECT * FROM payments WHERE note = ?", []any{"card 4111111111111111"}, []any{"card " + mask}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			if got := r.RedactArgs(tt.sql, tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RedactArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactCookie(t *testing.T) {
+	named := NewRedactor(DefaultRedactionConfig())
+	all := NewRedactor(RedactionConfig{Headers: []string{"Cookie", "Set-Cookie"}})
+	none := NewRedactor(RedactionConfig{})
+	mask := DefaultRedactionReplacement
+
+	tests := []struct {
+		name     string
+		redactor *Redactor
+		header   string
+		cookie   string
+		want     string
+	}{
+		{"session cookie", named, "Cookie", "SESSIONID", mask},
+		{"sensitive name", named, "Set-Cookie", "refresh_token", mask},
+		{"other cookie", named, "Cookie", "theme", "dark"},
+		{"all cookies under a redacted header", all, "Cookie", "theme", mask},
+		{"all cookies only under redacted headers", all, "X-Cookies", "theme", "dark"},
+		{"nothing configured", none, "Cookie", "theme", "dark"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.redactor.RedactCookie(tt.header, tt.cookie, "dark"); got != tt.want {
+				t.Errorf("RedactCookie(%q, %q) = %q, want %q", tt.header, tt.cookie, got, tt.want)
+			}
+		})
+	}
+
+	if !masksAllCookies(RedactionConfig{Headers: []string{"cookie", "SET-COOKIE"}}) {
+		t.Error("masksAllCookies() = false without cookie names")
+	}
+	if masksAllCookies(DefaultRedactionConfig()) {
+		t.Error("masksAllCookies() = true with cookie names")
+	}
+}
@@ -0,0 +1,322 @@
+package godebugbar
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Reasons reported when requests are evicted
+const (
+	EvictReasonCount  = "count"
+	EvictReasonBytes  = "bytes"
+	EvictReasonAge    = "age"
+	EvictReasonDelete = "delete"
+	EvictReasonClear  = "clear"
+)
+
+// RetentionPolicy decides how long captured requests are kept. Requests are
+// evicted oldest first from the lowest tier: routine requests go before
+// notable ones (errors and slow requests), and pinned requests are never
+// evicted.
+type RetentionPolicy struct {
+	// MaxBytes is an approximate budget for the total size of stored
+	// requests, measured as their JSON size. Zero means no limit.
+	MaxBytes int64
+
+	// MaxAge is how long routine requests are kept. Zero means no limit.
+	MaxAge time.Duration
+
+	// NotableMaxAge is how long notable requests are kept. Zero means no
+	// limit.
+	NotableMaxAge time.Duration
+
+	// KeepErrors makes requests with a 5xx status or logged errors notable
+	KeepErrors bool
+
+	// SlowThreshold makes requests taking at least this long notable. Zero
+	// disables it.
+	SlowThreshold time.Duration
+}
+
+// EvictionInfo reports requests removed from the history
+type EvictionInfo struct {
+	IDs    []string `json:"ids"`
+	Reason string   `json:"reason"`
+}
+
+// Retention tiers, in eviction order
+const (
+	tierRoutine = iota
+	tierNotable
+	tierPinned
+	tierCount
+)
+
+// retentionEntry tracks a stored request for the retention policy
+type retentionEntry struct {
+	id    string
+	seq   uint64
+	size  int64
+	added time.Time
+	tier  int
+}
+
+// retention applies a RetentionPolicy and the request count limit to the
+// requests in a store, which may be any Store implementation
+type retention struct {
+	mu       sync.Mutex
+	policy   RetentionPolicy
+	maxCount int
+	tiers    [tierCount]*list.List
+	entries  map[string]*list.Element
+	bytes    int64
+	seq      uint64
+}
+
+// newRetention creates a retention manager for the given limits
+func newRetention(policy RetentionPolicy, maxCount int) *retention {
+	r := &retention{
+		policy:   policy,
+		maxCount: maxCount,
+		entries:  make(map[string]*list.Element),
+	}
+	for i := range r.tiers {
+		r.tiers[i] = list.New()
+	}
+	return r
+}
+
+// tierOf returns the retention tier of a request
+func (r *retention) tierOf(req *RequestInfo) int {
+	if req.Pinned {
+		return tierPinned
+	}
+	if r.policy.SlowThreshold > 0 && req.Duration >= r.policy.SlowThreshold {
+		return tierNotable
+	}
+	if r.policy.KeepErrors {
+		if req.StatusCode >= 500 {
+			return tierNotable
+		}
+		for _, err := range req.Errors {
+			if err.Type == ErrorTypeException {
+				return tierNotable
+			}
+		}
+	}
+	return tierRoutine
+}
+
+// admit records a new request added at the given time, which ages are
+// measured from, and returns the requests that must be evicted to stay
+// within the limits. The new request itself is never evicted, so a single
+// oversized request may exceed the byte budget.
+func (r *retention) admit(req *RequestInfo, size int64, added, now time.Time) []EvictionInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, exists := r.entries[req.ID]; exists {
+		r.removeElement(element)
+	}
+
+	entry := &retentionEntry{
+		id:    req.ID,
+		seq:   r.seq,
+		size:  size,
+		added: added,
+		tier:  r.tierOf(req),
+	}
+	r.seq++
+	r.entries[req.ID] = r.tiers[entry.tier].PushBack(entry)
+	r.bytes += size
+
+	var evictions []EvictionInfo
+	add := func(reason, id string) {
+		if n := len(evictions); n > 0 && evictions[n-1].Reason == reason {
+			evictions[n-1].IDs = append(evictions[n-1].IDs, id)
+			return
+		}
+		evictions = append(evictions, EvictionInfo{IDs: []string{id}, Reason: reason})
+	}
+
+	for _, id := range r.expire(now, req.ID) {
+		add(EvictReasonAge, id)
+	}
+	for r.maxCount > 0 && len(r.entries) > r.maxCount {
+		id, ok := r.evictOne(req.ID)
+		if !ok {
+			break
+		}
+		add(EvictReasonCount, id)
+	}
+	for r.policy.MaxBytes > 0 && r.bytes > r.policy.MaxBytes {
+		id, ok := r.evictOne(req.ID)
+		if !ok {
+			break
+		}
+		add(EvictReasonBytes, id)
+	}
+
+	return evictions
+}
+
+// expire removes routine and notable requests older than their max age,
+// other than keep
+func (r *retention) expire(now time.Time, keep string) []string {
+	var expired []string
+	for tier, maxAge := range []time.Duration{r.policy.MaxAge, r.policy.NotableMaxAge} {
+		if maxAge <= 0 {
+			continue
+		}
+		cutoff := now.Add(-maxAge)
+		for element := r.tiers[tier].Front(); element != nil; {
+			next := element.Next()
+			entry := element.Value.(*retentionEntry)
+			if entry.id != keep && entry.added.Before(cutoff) {
+				r.removeElement(element)
+				expired = append(expired, entry.id)
+			}
+			element = next
+		}
+	}
+	return expired
+}
+
+// evictOne removes the oldest request from the lowest evictable tier,
+// other than keep
+func (r *retention) evictOne(keep string) (string, bool) {
+	for tier := tierRoutine; tier < tierPinned; tier++ {
+		for element := r.tiers[tier].Front(); element != nil; element = element.Next() {
+			entry := element.Value.(*retentionEntry)
+			if entry.id == keep {
+				continue
+			}
+			r.removeElement(element)
+			return entry.id, true
+		}
+	}
+	return "", false
+}
+
+// retier moves a request whose pin or size changed to its new tier,
+// keeping each tier ordered oldest first
+func (r *retention) retier(req *RequestInfo, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[req.ID]
+	if !ok {
+		return
+	}
+	entry := element.Value.(*retentionEntry)
+	r.bytes += size - entry.size
+	entry.size = size
+
+	tier := r.tierOf(req)
+	if tier == entry.tier {
+		return
+	}
+	r.tiers[entry.tier].Remove(element)
+	entry.tier = tier
+
+	target := r.tiers[tier]
+	for older := target.Back(); older != nil; older = older.Prev() {
+		if older.Value.(*retentionEntry).seq < entry.seq {
+			r.entries[req.ID] = target.InsertAfter(entry, older)
+			return
+		}
+	}
+	r.entries[req.ID] = target.PushFront(entry)
+}
+
+// pinned returns the number of pinned requests
+func (r *retention) pinned() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tiers[tierPinned].Len()
+}
+
+// remove forgets a request deleted from the store
+func (r *retention) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, ok := r.entries[id]; ok {
+		r.removeElement(element)
+	}
+}
+
+// ids returns the IDs of every tracked request
+func (r *retention) ids() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0, len(r.entries))
+	for id := range r.entries {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// reset forgets every request
+func (r *retention) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tier := range r.tiers {
+		tier.Init()
+	}
+	clear(r.entries)
+	r.bytes = 0
+}
+
+// removeElement drops an entry from its tier and the totals
+func (r *retention) removeElement(element *list.Element) {
+	entry := element.Value.(*retentionEntry)
+	r.tiers[entry.tier].Remove(element)
+	delete(r.entries, entry.id)
+	r.bytes -= entry.size
+}
+
+// sweep expires requests past their max age and returns their IDs. The
+// debug bar runs it periodically so requests expire without new traffic.
+func (r *retention) sweep(now time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.expire(now, "")
+}
+
+// sweepInterval returns how often to sweep for expired requests, or 0 when
+// the policy has no age limit
+func (r *retention) sweepInterval() time.Duration {
+	shortest := time.Duration(0)
+	for _, maxAge := range []time.Duration{r.policy.MaxAge, r.policy.NotableMaxAge} {
+		if maxAge > 0 && (shortest == 0 || maxAge < shortest) {
+			shortest = maxAge
+		}
+	}
+	if shortest == 0 {
+		return 0
+	}
+	return min(max(shortest/10, time.Second), time.Minute)
+}
+
+// sizeOf returns the size retention accounts a request for. Measuring is
+// only needed for a byte budget, so it is 0 without one.
+func (r *retention) sizeOf(req *RequestInfo) int64 {
+	if r.policy.MaxBytes <= 0 {
+		return 0
+	}
+	return requestSize(req)
+}
+
+// requestSize approximates the memory a request takes by its JSON size
+func requestSize(req *RequestInfo) int64 {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
@@ -0,0 +1,144 @@
+package godebugbar
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRetentionAdmit(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// retained is a request admitted at an offset from start
+	type retained struct {
+		req  *RequestInfo
+		size int64
+		at   time.Duration
+	}
+
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		maxCount int
+		admit    []retained
+		want     []string
+		evicted  []EvictionInfo
+	}{
+		{
+			name:     "count evicts the oldest",
+			maxCount: 2,
+			admit: []retained{
+				{req: &RequestInfo{ID: "a"}},
+				{req: &RequestInfo{ID: "b"}},
+				{req: &RequestInfo{ID: "c"}},
+			},
+			want:    []string{"b", "c"},
+			evicted: []EvictionInfo{{IDs: []string{"a"}, Reason: EvictReasonCount}},
+		},
+		{
+			name:     "routine requests go before notable ones",
+			policy:   RetentionPolicy{KeepErrors: true, SlowThreshold: time.Second},
+			maxCount: 2,
+			admit: []retained{
+				{req: &RequestInfo{ID: "routine"}},
+				{req: &RequestInfo{ID: "error", StatusCode: 500}},
+				{req: &RequestInfo{ID: "slow", Duration: 2 * time.Second}},
+			},
+			want:    []string{"error", "slow"},
+			evicted: []EvictionInfo{{IDs: []string{"routine"}, Reason: EvictReasonCount}},
+		},
+		{
+			name:     "pinned requests are never evicted",
+			maxCount: 2,
+			admit: []retained{
+				{req: &RequestInfo{ID: "pinned", Pinned: true}},
+				{req: &RequestInfo{ID: "a"}},
+				{req: &RequestInfo{ID: "b"}},
+			},
+			want:    []string{"b", "pinned"},
+			evicted: []EvictionInfo{{IDs: []string{"a"}, Reason: EvictReasonCount}},
+		},
+		{
+			name:   "byte budget",
+			policy: RetentionPolicy{MaxBytes: 100},
+			admit: []retained{
+				{req: &RequestInfo{ID: "a"}, size: 40},
+				{req: &RequestInfo{ID: "b"}, size: 40},
+				{req: &RequestInfo{ID: "c"}, size: 60},
+			},
+			want:    []string{"b", "c"},
+			evicted: []EvictionInfo{{IDs: []string{"a"}, Reason: EvictReasonBytes}},
+		},
+		{
+			name:   "an oversized request is kept",
+			policy: RetentionPolicy{MaxBytes: 100},
+			admit: []retained{
+				{req: &RequestInfo{ID: "a"}, size: 40},
+				{req: &RequestInfo{ID: "huge"}, size: 500},
+			},
+			want:    []string{"huge"},
+			evicted: []EvictionInfo{{IDs: []string{"a"}, Reason: EvictReasonBytes}},
+		},
+		{
+			name:   "age limits per tier",
+			policy: RetentionPolicy{MaxAge: time.Minute, NotableMaxAge: time.Hour, KeepErrors: true},
+			admit: []retained{
+				{req: &RequestInfo{ID: "old"}},
+				{req: &RequestInfo{ID: "old-error", StatusCode: 500}},
+				{req: &RequestInfo{ID: "new"}, at: 2 * time.Minute},
+			},
+			want:    []string{"new", "old-error"},
+			evicted: []EvictionInfo{{IDs: []string{"old"}, Reason: EvictReasonAge}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRetention(tt.policy, tt.maxCount)
+			var evicted []EvictionInfo
+			for _, admit := range tt.admit {
+				at := start.Add(admit.at)
+				evicted = append(evicted, r.admit(admit.req, admit.size, at, at)...)
+			}
+
+			got := r.ids()
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("retained %v, want %v", got, tt.want)
+			}
+			if !slices.EqualFunc(evicted, tt.evicted, func(a, b EvictionInfo) bool {
+				return a.Reason == b.Reason && slices.Equal(a.IDs, b.IDs)
+			}) {
+				t.Errorf("evicted %+v, want %+v", evicted, tt.evicted)
+			}
+		})
+	}
+}
+
+func TestRetentionSweep(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := newRetention(RetentionPolicy{MaxAge: time.Minute}, 0)
+	r.admit(&RequestInfo{ID: "a"}, 0, start, start)
+	r.admit(&RequestInfo{ID: "pinned", Pinned: true}, 0, start, start)
+	r.admit(&RequestInfo{ID: "b"}, 0, start.Add(time.Minute), start.Add(time.Minute))
+
+	if expired := r.sweep(start.Add(90 * time.Second)); !slices.Equal(expired, []string{"a"}) {
+		t.Errorf("sweep expired %v, want [a]", expired)
+	}
+	if expired := r.sweep(start.Add(3 * time.Minute)); !slices.Equal(expired, []string{"b"}) {
+		t.Errorf("sweep expired %v, want [b]", expired)
+	}
+	if got := r.ids(); !slices.Equal(got, []string{"pinned"}) {
+		t.Errorf("retained %v, want [pinned]", got)
+	}
+}
+
+func TestRetentionSizeOf(t *testing.T) {
+	req := &RequestInfo{ID: "a", Path: "/orders"}
+	if size := newRetention(RetentionPolicy{}, 10).sizeOf(req); size != 0 {
+		t.Errorf("sizeOf without a byte budget = %d, want 0", size)
+	}
+	if size := newRetention(RetentionPolicy{MaxBytes: 1 << 20}, 10).sizeOf(req); size != requestSize(req) {
+		t.Errorf("sizeOf with a byte budget = %d, want %d", size, requestSize(req))
+	}
+}
@@ -0,0 +1,415 @@
+package godebugbar
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultSearchLimit is the page size used when a search doesn't set one
+const DefaultSearchLimit = 50
+
+// SearchQuery is a parsed search over captured requests. Terms are
+// separated by spaces and must all match:
+//
+//	method:POST path:/orders* status:>=500 queries:>20 duration:>200ms
+//	has:error user:42 sql:"FROM orders"
+//
+// A term prefixed with "-" must not match, and a term without a field
+// matches a substring of the path.
+type SearchQuery struct {
+	terms []searchTerm
+}
+
+// SearchError reports an invalid search term
+type SearchError struct {
+	Term    string
+	Message string
+}
+
+// Error implements error
+func (e *SearchError) Error() string {
+	return fmt.Sprintf("search term %q: %s", e.Term, e.Message)
+}
+
+// SearchPage selects a page of search results, newest first
+type SearchPage struct {
+	// Offset is the number of matches to skip
+	Offset int
+
+	// Limit is the number of matches to return, DefaultSearchLimit when 0
+	Limit int
+}
+
+// SearchResult is a page of requests matching a search
+type SearchResult struct {
+	IDs    []string `json:"ids"`
+	Total  int      `json:"total"`
+	Offset int      `json:"offset"`
+	Limit  int      `json:"limit"`
+}
+
+// searchTerm is a single condition of a search
+type searchTerm struct {
+	negate bool
+	match  func(*RequestInfo) bool
+
+	// conditions hold for every request the term matches, so stores can
+	// filter in their database before applying match. exact is set when
+	// they hold for no other request.
+	conditions []fieldCondition
+	exact      bool
+}
+
+// fieldCondition compares a stored request field, named after its column
+type fieldCondition struct {
+	column string
+	op     string // =, !=, <, <=, >, >= or "like" for a glob pattern
+	value  any
+}
+
+// ParseSearch parses a search query. An empty query matches every request.
+func ParseSearch(query string) (*SearchQuery, error) {
+	tokens, err := splitSearch(query)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &SearchQuery{}
+	for _, token := range tokens {
+		term, err := parseSearchTerm(token)
+		if err != nil {
+			return nil, err
+		}
+		q.terms = append(q.terms, term)
+	}
+	return q, nil
+}
+
+// Match reports whether a request matches every term of the search
+func (q *SearchQuery) Match(req *RequestInfo) bool {
+	for _, term := range q.terms {
+		if term.match(req) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns the IDs of stored requests matching query, newest first.
+// It filters through Store.List, so it works with any store.
+func (d *DebugBar) Search(query string, page SearchPage) (*SearchResult, error) {
+	q, err := ParseSearch(query)
+	if err != nil {
+		return nil, err
+	}
+	if page.Offset < 0 {
+		return nil, fmt.Errorf("search offset must not be negative")
+	}
+	if page.Limit <= 0 {
+		page.Limit = DefaultSearchLimit
+	}
+
+	requests, err := d.store.List(StoreFilter{Match: q.Match, Search: q})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{
+		IDs:    make([]string, 0, min(page.Limit, max(len(requests)-page.Offset, 0))),
+		Total:  len(requests),
+		Offset: page.Offset,
+		Limit:  page.Limit,
+	}
+	for i := len(requests) - 1 - page.Offset; i >= 0 && len(result.IDs) < page.Limit; i-- {
+		result.IDs = append(result.IDs, requests[i].ID)
+	}
+	return result, nil
+}
+
+// splitSearch splits a query into terms at spaces outside double quotes
+func splitSearch(query string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	quoted, started := false, false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case unicode.IsSpace(r) && !quoted:
+			if started {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				started = false
+			}
+		default:
+			token.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, &SearchError{Term: query, Message: "unterminated quote"}
+	}
+	if started {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
+
+// parseSearchTerm parses a single field:value term
+func parseSearchTerm(token string) (searchTerm, error) {
+	term := searchTerm{}
+	if strings.HasPrefix(token, "-") && len(token) > 1 {
+		term.negate = true
+		token = token[1:]
+	}
+
+	field, value, ok := strings.Cut(token, ":")
+	if !ok {
+		term.match = containsFold(func(req *RequestInfo) string { return req.Path }, token)
+		return term, nil
+	}
+	if value == "" {
+		return term, &SearchError{Term: token, Message: "missing value"}
+	}
+
+	var err error
+	switch strings.ToLower(field) {
+	case "method":
+		term.match = func(req *RequestInfo) bool { return strings.EqualFold(req.Method, value) }
+		term.conditions, term.exact = []fieldCondition{{"method", "=", strings.ToUpper(value)}}, true
+	case "path":
+		term.match = globMatch(func(req *RequestInfo) string { return req.Path }, value)
+		term.conditions, term.exact = globConditions("path", value)
+	case "route":
+		term.match = globMatch(func(req *RequestInfo) string { return req.Route }, value)
+		term.conditions, term.exact = globConditions("route", value)
+	case "status":
+		term.match, err = statusMatch(value)
+		term.conditions, term.exact = statusConditions(value), true
+	case "queries":
+		term.match, err = numberMatch(value, func(req *RequestInfo) float64 { return float64(len(req.Queries)) })
+	case "errors":
+		term.match, err = numberMatch(value, func(req *RequestInfo) float64 { return float64(len(req.Errors)) })
+	case "duration":
+		term.match, err = durationMatch(value)
+		if err == nil {
+			op, operand := splitComparison(value)
+			term.conditions, term.exact = []fieldCondition{{"duration", op, int64(parseDuration(operand))}}, true
+		}
+	case "has":
+		term.match, err = hasMatch(value)
+	case "user":
+		term.match = func(req *RequestInfo) bool { return matchUser(req, value) }
+	case "sql":
+		term.match = func(req *RequestInfo) bool {
+			for _, query := range req.Queries {
+				if containsFoldString(query.Query, value) {
+					return true
+				}
+			}
+			return false
+		}
+	case "label":
+		term.match = func(req *RequestInfo) bool {
+			return slices.ContainsFunc(req.Labels, func(label string) bool { return strings.EqualFold(label, value) })
+		}
+	case "note":
+		term.match = func(req *RequestInfo) bool {
+			return slices.ContainsFunc(req.Notes, func(note RequestNote) bool { return containsFoldString(note.Text, value) })
+		}
+	case "ip":
+		term.match = func(req *RequestInfo) bool { return req.ClientIP == value }
+	default:
+		return term, &SearchError{Term: token, Message: "unknown field " + strconv.Quote(field)}
+	}
+	if err != nil {
+		return term, &SearchError{Term: token, Message: err.Error()}
+	}
+	return term, nil
+}
+
+// globMatch matches a field against a wildcard pattern. Unlike capture
+// rule globs, "*" also matches across path segments, so /orders* finds
+// /orders/5. A value without wildcards must match exactly.
+func globMatch(field func(*RequestInfo) string, glob string) func(*RequestInfo) bool {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			pattern.WriteString(".*")
+		case '?':
+			pattern.WriteString(".")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	pattern.WriteString("$")
+	re := regexp.MustCompile(pattern.String())
+	return func(req *RequestInfo) bool { return re.MatchString(field(req)) }
+}
+
+// containsFold matches a field containing value, ignoring case
+func containsFold(field func(*RequestInfo) string, value string) func(*RequestInfo) bool {
+	return func(req *RequestInfo) bool { return containsFoldString(field(req), value) }
+}
+
+// containsFoldString reports whether s contains substr, ignoring case
+func containsFoldString(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// statusClass matches status classes such as 5xx
+var statusClass = regexp.MustCompile(`^[1-5][xX][xX]$`)
+
+// statusMatch matches the status code, either against a comparison such as
+// >=500 or a class such as 4xx
+func statusMatch(value string) (func(*RequestInfo) bool, error) {
+	if statusClass.MatchString(value) {
+		class := int(value[0] - '0')
+		return func(req *RequestInfo) bool { return req.StatusCode/100 == class }, nil
+	}
+	return numberMatch(value, func(req *RequestInfo) float64 { return float64(req.StatusCode) })
+}
+
+// statusConditions returns the column conditions of a valid status term
+func statusConditions(value string) []fieldCondition {
+	if statusClass.MatchString(value) {
+		class := int(value[0] - '0')
+		return []fieldCondition{
+			{"status_code", ">=", class * 100},
+			{"status_code", "<", (class + 1) * 100},
+		}
+	}
+	op, operand := splitComparison(value)
+	limit, _ := strconv.ParseFloat(operand, 64)
+	return []fieldCondition{{"status_code", op, limit}}
+}
+
+// globConditions returns the column conditions of a path or route glob.
+// Wildcards become a like pattern, which only narrows the candidates as
+// some databases compare it case-insensitively.
+func globConditions(column, glob string) ([]fieldCondition, bool) {
+	if !strings.ContainsAny(glob, "*?") {
+		return []fieldCondition{{column, "=", glob}}, true
+	}
+	return []fieldCondition{{column, "like", glob}}, false
+}
+
+// durationMatch compares the request duration. Bare numbers are
+// milliseconds.
+func durationMatch(value string) (func(*RequestInfo) bool, error) {
+	op, operand := splitComparison(value)
+	limit := parseDuration(operand)
+	if limit < 0 {
+		return nil, fmt.Errorf("invalid duration %q", operand)
+	}
+	return func(req *RequestInfo) bool {
+		return compare(op, float64(req.Duration), float64(limit))
+	}, nil
+}
+
+// parseDuration parses a duration such as 200ms or a bare number of
+// milliseconds, returning -1 when it is invalid
+func parseDuration(value string) time.Duration {
+	if limit, err := time.ParseDuration(value); err == nil {
+		return limit
+	}
+	ms, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return -1
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// numberMatch compares a numeric field
+func numberMatch(value string, field func(*RequestInfo) float64) (func(*RequestInfo) bool, error) {
+	op, operand := splitComparison(value)
+	limit, err := strconv.ParseFloat(operand, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", operand)
+	}
+	return func(req *RequestInfo) bool { return compare(op, field(req), limit) }, nil
+}
+
+// splitComparison separates a comparison operator from its operand
+func splitComparison(value string) (string, string) {
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if operand, ok := strings.CutPrefix(value, op); ok {
+			return op, operand
+		}
+	}
+	return "=", value
+}
+
+// compare applies a comparison operator
+func compare(op string, a, b float64) bool {
+	switch op {
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	default:
+		return a == b
+	}
+}
+
+// hasMatch matches requests that have a kind of data
+func hasMatch(value string) (func(*RequestInfo) bool, error) {
+	switch strings.ToLower(value) {
+	case "error", "errors":
+		return func(req *RequestInfo) bool { return hasErrorType(req, ErrorTypeException) }, nil
+	case "warning", "warnings":
+		return func(req *RequestInfo) bool { return hasErrorType(req, ErrorTypeWarning) }, nil
+	case "query", "queries":
+		return func(req *RequestInfo) bool { return len(req.Queries) > 0 }, nil
+	case "user":
+		return func(req *RequestInfo) bool { return req.User != nil }, nil
+	case "body":
+		return func(req *RequestInfo) bool { return req.RequestBody != "" }, nil
+	case "session":
+		return func(req *RequestInfo) bool { return req.Session != nil }, nil
+	case "validation":
+		return func(req *RequestInfo) bool { return len(req.ValidationErrors) > 0 }, nil
+	case "pinned":
+		return func(req *RequestInfo) bool { return req.Pinned }, nil
+	case "note", "notes":
+		return func(req *RequestInfo) bool { return len(req.Notes) > 0 }, nil
+	case "label", "labels":
+		return func(req *RequestInfo) bool { return len(req.Labels) > 0 }, nil
+	default:
+		return nil, fmt.Errorf("unknown has: value %q", value)
+	}
+}
+
+// hasErrorType reports whether a request logged an error of the given type
+func hasErrorType(req *RequestInfo, errorType string) bool {
+	for _, err := range req.Errors {
+		if err.Type == errorType {
+			return true
+		}
+	}
+	return false
+}
+
+// matchUser matches a user ID exactly or a case-insensitive substring of
+// the user's name
+func matchUser(req *RequestInfo, query string) bool {
+	if req.User == nil {
+		return false
+	}
+	return req.User.ID == query ||
+		(req.User.Name != "" && containsFoldString(req.User.Name, query))
+}
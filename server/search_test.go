@@ -0,0 +1,145 @@
+package godebugbar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSearch(t *testing.T) {
+	req := &RequestInfo{
+		Method:     "POST",
+		Path:       "/orders/42/items",
+		Route:      "/orders/:id/items",
+		StatusCode: 502,
+		Duration:   350 * time.Millisecond,
+		ClientIP:   "10.0.0.7",
+		User:       &UserInfo{ID: "42", Name: "Ada Lovelace"},
+		Queries:    []QueryInfo{{Query: "SELECT * FROM orders WHERE id = ?"}},
+		Errors:     []ErrorInfo{{Type: ErrorTypeException}},
+		Labels:     []string{"Checkout"},
+		Notes:      []RequestNote{{Text: "Repro for the timeout"}},
+	}
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"method:post", true},
+		{"method:GET", false},
+		{"path:/orders/42/items", true},
+		{"path:/orders", false},
+		{"path:/orders*", true},
+		{"path:/orders/4?/items", true},
+		{"route:/orders/:id/*", true},
+		{"orders/42", true},
+		{"ORDERS", true},
+		{"status:502", true},
+		{"status:5xx", true},
+		{"status:4XX", false},
+		{"status:>=500", true},
+		{"status:<500", false},
+		{"status:!=502", false},
+		{"duration:>200ms", true},
+		{"duration:>1s", false},
+		{"duration:<=350", true},
+		{"queries:1", true},
+		{"queries:>1", false},
+		{"errors:>0", true},
+		{"has:error", true},
+		{"has:warning", false},
+		{"has:user", true},
+		{"has:body", false},
+		{"user:42", true},
+		{"user:ada", true},
+		{"user:7", false},
+		{`sql:"from orders"`, true},
+		{"sql:users", false},
+		{"label:checkout", true},
+		{"note:timeout", true},
+		{"ip:10.0.0.7", true},
+		{"-method:GET", true},
+		{"-status:5xx", false},
+		{"method:POST status:5xx has:error", true},
+		{"method:POST status:2xx", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseSearch(tt.query)
+			if err != nil {
+				t.Fatalf("ParseSearch(%q) error = %v", tt.query, err)
+			}
+			if got := q.Match(req); got != tt.want {
+				t.Errorf("ParseSearch(%q).Match() = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		term  string
+	}{
+		{`sql:"unterminated`, `sql:"unterminated`},
+		{"method:", "method:"},
+		{"color:red", "color:red"},
+		{"status:abc", "status:abc"},
+		{"duration:fast", "duration:fast"},
+		{"queries:many", "queries:many"},
+		{"has:everything", "has:everything"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseSearch(tt.query)
+			var searchErr *SearchError
+			if !errors.As(err, &searchErr) {
+				t.Fatalf("ParseSearch(%q) error = %v, want a *SearchError", tt.query, err)
+			}
+			if searchErr.Term != tt.term {
+				t.Errorf("SearchError.Term = %q, want %q", searchErr.Term, tt.term)
+			}
+		})
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	d := New(Config{Enabled: true, MaxRequests: 10})
+	for _, path := range []string{"/a", "/b", "/a/1", "/c", "/a/2"} {
+		d.storeRequest(&RequestInfo{ID: path, Path: path})
+	}
+
+	tests := []struct {
+		name  string
+		page  SearchPage
+		want  []string
+		total int
+	}{
+		{"newest first", SearchPage{}, []string{"/a/2", "/a/1", "/a"}, 3},
+		{"limit", SearchPage{Limit: 2}, []string{"/a/2", "/a/1"}, 3},
+		{"offset", SearchPage{Offset: 2}, []string{"/a"}, 3},
+		{"offset past the end", SearchPage{Offset: 5}, []string{}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := d.Search("path:/a*", tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != tt.total {
+				t.Errorf("Total = %d, want %d", result.Total, tt.total)
+			}
+			if len(result.IDs) != len(tt.want) {
+				t.Fatalf("IDs = %v, want %v", result.IDs, tt.want)
+			}
+			for i := range tt.want {
+				if result.IDs[i] != tt.want[i] {
+					t.Fatalf("IDs = %v, want %v", result.IDs, tt.want)
+				}
+			}
+		})
+	}
+}
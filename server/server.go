@@ -0,0 +1,237 @@
+package godebugbar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportSize limits the size of archives uploaded to the import endpoint
+const maxImportSize = 256 << 20
+
+// archiveContentTypes are the content types the import endpoint accepts.
+// None of them can be sent cross-site by a form.
+var archiveContentTypes = map[string]bool{
+	"application/gzip":     true,
+	"application/x-gzip":   true,
+	"application/x-ndjson": true,
+}
+
+// ErrServerRunning is returned when starting a debug bar listener while one
+// is already running
+var ErrServerRunning = errors.New("debug bar server is already running")
+
+// Handler returns an http.Handler serving the debug bar endpoints: the
+// dashboard at /, the WebSocket at WebSocketPath and the JSON API under
+// APIPath. Every request goes through the configured Auth.
+func (d *DebugBar) Handler() http.Handler {
+	if !d.config.Enabled {
+		return http.NotFoundHandler()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.serveDashboard)
+	mux.HandleFunc("GET "+d.config.WebSocketPath, d.serveWebSocket)
+	mux.HandleFunc("GET "+d.config.APIPath+"/requests", d.serveRequests)
+	mux.HandleFunc("DELETE "+d.config.APIPath+"/requests", d.serveClearRequests)
+	mux.HandleFunc("GET "+d.config.APIPath+"/requests/{id}", d.serveRequest)
+	mux.HandleFunc("DELETE "+d.config.APIPath+"/requests/{id}", d.serveDeleteRequest)
+	mux.HandleFunc("GET "+d.config.APIPath+"/export", d.serveExport)
+	mux.HandleFunc("POST "+d.config.APIPath+"/import", d.serveImport)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.authenticate(w, r) {
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// ListenAndServe runs the debug bar endpoints on their own HTTP server at
+// addr, such as "127.0.0.1:9999", keeping them off the application's port.
+// Like http.Server it returns http.ErrServerClosed after Shutdown.
+func (d *DebugBar) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return d.Serve(listener)
+}
+
+// Serve runs the debug bar endpoints on listener until Shutdown is called
+func (d *DebugBar) Serve(listener net.Listener) error {
+	if !d.config.Enabled {
+		listener.Close()
+		return errors.New("debug bar is disabled")
+	}
+
+	server := &http.Server{
+		Handler:           d.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Shutdown doesn't track hijacked connections, so close the WebSockets
+	// opened through this server. Clients on the application's router stay.
+	server.RegisterOnShutdown(func() { d.wsHub.closeServer(server) })
+
+	d.mu.Lock()
+	if d.server != nil {
+		d.mu.Unlock()
+		listener.Close()
+		return ErrServerRunning
+	}
+	d.server = server
+	d.mu.Unlock()
+
+	return server.Serve(listener)
+}
+
+// Shutdown gracefully stops the server started by ListenAndServe or Serve
+// and disconnects its WebSocket clients. The middleware keeps capturing.
+func (d *DebugBar) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	server := d.server
+	d.server = nil
+	d.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// serveRequests returns the stored requests, optionally only the most
+// recent ones given by the limit parameter
+func (d *DebugBar) serveRequests(w http.ResponseWriter, r *http.Request) {
+	filter := StoreFilter{}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	requests, err := d.store.List(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// serveRequest returns a single stored request
+func (d *DebugBar) serveRequest(w http.ResponseWriter, r *http.Request) {
+	req, err := d.store.Get(r.PathValue("id"))
+	if errors.Is(err, ErrRequestNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+// serveDeleteRequest deletes a single stored request
+func (d *DebugBar) serveDeleteRequest(w http.ResponseWriter, r *http.Request) {
+	err := d.DeleteRequest(r.PathValue("id"))
+	if errors.Is(err, ErrRequestNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrReadOnly) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveClearRequests clears the stored requests
+func (d *DebugBar) serveClearRequests(w http.ResponseWriter, r *http.Request) {
+	err := d.clearHistory()
+	if errors.Is(err, ErrReadOnly) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveExport downloads the requests matching the q parameter, or all
+// requests, as an archive
+func (d *DebugBar) serveExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if _, err := ParseSearch(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filename := "debugbar-" + d.clock().UTC().Format("20060102-150405") + ".ndjson.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if _, err := d.Export(w, query); err != nil {
+		// The response has started, so the error can only be logged
+		d.logger.Printf("Debug bar export error: %v", err)
+	}
+}
+
+// serveImport loads an uploaded archive into the history. Browsers can't
+// send a cross-site request with an archive content type without a CORS
+// preflight, and the Origin is checked too, so other sites can't make a
+// developer's browser import into the history.
+func (d *DebugBar) serveImport(w http.ResponseWriter, r *http.Request) {
+	if !d.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !archiveContentTypes[strings.ToLower(mediaType)] {
+		http.Error(w, "archives must be sent as application/gzip or application/x-ndjson", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	n, err := d.Import(http.MaxBytesReader(w, r.Body, maxImportSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || errors.Is(err, ErrArchiveTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, ErrInvalidArchive) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"imported": n})
+}
+
+// isDebugBarPath reports whether a path belongs to the debug bar's own
+// endpoints, which are never captured
+func (d *DebugBar) isDebugBarPath(path string) bool {
+	return path == d.config.WebSocketPath ||
+		(d.config.APIPath != "" && (path == d.config.APIPath || strings.HasPrefix(path, d.config.APIPath+"/")))
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,173 @@
+package godebugbar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHandler(t *testing.T) {
+	d := New(testConfig())
+	d.store.Add(&RequestInfo{ID: "r1", Method: http.MethodGet, Path: "/a"})
+	d.store.Add(&RequestInfo{ID: "r2", Method: http.MethodPost, Path: "/b"})
+	handler := d.Handler()
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := do(http.MethodGet, "/")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("dashboard: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if body := w.Body.String(); !strings.Contains(body, d.config.WebSocketPath) || !strings.Contains(body, d.config.APIPath) {
+		t.Error("dashboard doesn't reference the configured endpoints")
+	}
+	if w.Header().Get("Content-Security-Policy") == "" {
+		t.Error("dashboard has no Content-Security-Policy")
+	}
+
+	var list []*RequestInfo
+	w = do(http.MethodGet, d.config.APIPath+"/requests")
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK || len(list) != 2 {
+		t.Errorf("list: status %d, %d requests, %v", w.Code, len(list), err)
+	}
+	w = do(http.MethodGet, d.config.APIPath+"/requests?limit=1")
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].ID != "r2" {
+		t.Errorf("limited list = %v, %v, want the most recent request", list, err)
+	}
+
+	var req RequestInfo
+	w = do(http.MethodGet, d.config.APIPath+"/requests/r1")
+	if err := json.Unmarshal(w.Body.Bytes(), &req); err != nil || req.Path != "/a" {
+		t.Errorf("get: status %d, %+v, %v", w.Code, req, err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/requests?limit=0", http.StatusBadRequest},
+		{http.MethodGet, "/requests/missing", http.StatusNotFound},
+		{http.MethodDelete, "/requests/missing", http.StatusNotFound},
+		{http.MethodDelete, "/requests/r1", http.StatusNoContent},
+		{http.MethodGet, "/requests/r1", http.StatusNotFound},
+		{http.MethodGet, "/export?q=status:", http.StatusBadRequest},
+		{http.MethodPost, "/import", http.StatusUnsupportedMediaType},
+		{http.MethodDelete, "/requests", http.StatusNoContent},
+		{http.MethodPut, "/requests", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if w := do(tt.method, d.config.APIPath+tt.path); w.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, w.Code, tt.status)
+		}
+	}
+	if len(d.GetHistory()) != 0 {
+		t.Error("DELETE /requests didn't clear the history")
+	}
+
+	if w := do(http.MethodGet, "/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown path: status %d, want 404", w.Code)
+	}
+}
+
+func TestHandlerDisabled(t *testing.T) {
+	config := testConfig()
+	config.Enabled = false
+	d := New(config)
+
+	w := httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404 when disabled", w.Code)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Serve(listener); err == nil {
+		t.Error("Serve() succeeded when disabled")
+	}
+}
+
+func TestServeAndShutdown(t *testing.T) {
+	d, err := NewWithOptions(WithConfig(testConfig()), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.store.Add(&RequestInfo{ID: "r1"})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- d.Serve(listener) }()
+	addr := listener.Addr().String()
+
+	resp, err := http.Get("http://" + addr + d.config.APIPath + "/requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("API status %d, want 200", resp.StatusCode)
+	}
+
+	if err := d.ListenAndServe("127.0.0.1:0"); !errors.Is(err, ErrServerRunning) {
+		t.Errorf("second ListenAndServe() error = %v, want ErrServerRunning", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+d.config.WebSocketPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg WebSocketMessage
+	line, _, _ := strings.Cut(string(data), "\n")
+	if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Type != "history" {
+		t.Errorf("first message = %q, want the history", line)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Serve() returned %v, want http.ErrServerClosed", err)
+	}
+
+	// The WebSocket client is told the server is going away
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("WebSocket read error = %v, want a going away close", err)
+	}
+
+	if err := d.Shutdown(ctx); err != nil {
+		t.Errorf("second Shutdown() error = %v, want nil", err)
+	}
+}
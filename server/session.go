@@ -0,0 +1,274 @@
+package godebugbar
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CookieInfo describes a request cookie or a cookie set by the response.
+// Attributes are only known for response cookies.
+type CookieInfo struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	MaxAge   int        `json:"max_age,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	HttpOnly bool       `json:"http_only,omitempty"`
+	SameSite string     `json:"same_site,omitempty"`
+}
+
+// CookieCollector records the cookies sent with the request and those set
+// by the response
+type CookieCollector struct{}
+
+// NewCookieCollector creates a collector for request and response cookies
+func NewCookieCollector() *CookieCollector {
+	return &CookieCollector{}
+}
+
+// Collect implements Collector
+func (k *CookieCollector) Collect(c *gin.Context, req *RequestInfo) {
+	for _, cookie := range c.Request.Cookies() {
+		req.RequestCookies = append(req.RequestCookies, CookieInfo{
+			Name:  cookie.Name,
+			Value: cookie.Value,
+		})
+	}
+
+	for _, line := range c.Writer.Header().Values("Set-Cookie") {
+		cookie, err := http.ParseSetCookie(line)
+		if err != nil {
+			continue
+		}
+		info := CookieInfo{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			MaxAge:   cookie.MaxAge,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			SameSite: sameSiteName(cookie.SameSite),
+		}
+		if !cookie.Expires.IsZero() {
+			expires := cookie.Expires
+			info.Expires = &expires
+		}
+		req.ResponseCookies = append(req.ResponseCookies, info)
+	}
+}
+
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
+
+// SessionAdapter reads the current session values for a request. Adapters
+// wrap whatever session library the application uses.
+type SessionAdapter interface {
+	Values(c *gin.Context) (map[string]any, error)
+}
+
+// SessionAdapterFunc adapts a function to the SessionAdapter interface
+type SessionAdapterFunc func(c *gin.Context) (map[string]any, error)
+
+// Values calls f(c)
+func (f SessionAdapterFunc) Values(c *gin.Context) (map[string]any, error) {
+	return f(c)
+}
+
+// Session change types
+const (
+	SessionChangeAdded   = "added"
+	SessionChangeRemoved = "removed"
+	SessionChangeChanged = "changed"
+)
+
+// SessionInfo holds session values at the start and end of a request and
+// the changes the request made
+type SessionInfo struct {
+	Before  map[string]any  `json:"before"`
+	After   map[string]any  `json:"after"`
+	Changes []SessionChange `json:"changes,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// SessionChange is a single session key written or removed by a request
+type SessionChange struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// SessionCollector records session values before and after the handler
+// chain. Add its Middleware after the session middleware so the session is
+// loaded, and add the collector itself to Config.Collectors.
+type SessionCollector struct {
+	adapter SessionAdapter
+}
+
+// NewSessionCollector creates a session collector using the given adapter
+func NewSessionCollector(adapter SessionAdapter) *SessionCollector {
+	return &SessionCollector{adapter: adapter}
+}
+
+// Middleware returns a Gin middleware that snapshots the session values at
+// the start of the request
+func (s *SessionCollector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if req := requestInfoFromGin(c); req != nil {
+			values, err := s.adapter.Values(c)
+			req.Session = &SessionInfo{Before: copyValues(values)}
+			if err != nil {
+				req.Session.Error = err.Error()
+			}
+		}
+		c.Next()
+	}
+}
+
+// Collect implements Collector
+func (s *SessionCollector) Collect(c *gin.Context, req *RequestInfo) {
+	if req.Session == nil {
+		// The middleware didn't run, so there's nothing to compare with
+		req.Session = &SessionInfo{}
+	}
+
+	values, err := s.adapter.Values(c)
+	if err != nil {
+		req.Session.Error = err.Error()
+		return
+	}
+	req.Session.After = copyValues(values)
+	req.Session.Changes = diffValues(req.Session.Before, req.Session.After)
+}
+
+// copyValues returns a deep copy of a session value map, so values the
+// handler changes in place still show up as changes
+func copyValues(values map[string]any) map[string]any {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string]any, len(values))
+	for key, value := range values {
+		if value == nil {
+			result[key] = nil
+			continue
+		}
+		result[key] = copyValue(reflect.ValueOf(value), 0).Interface()
+	}
+	return result
+}
+
+// maxCopyDepth bounds copyValue so cyclic values end in a shallow copy
+const maxCopyDepth = 32
+
+// copyValue deep copies maps, slices, pointers and the exported fields of
+// structs. Other values, and anything deeper than maxCopyDepth, are copied
+// as-is.
+func copyValue(v reflect.Value, depth int) reflect.Value {
+	if depth >= maxCopyDepth {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), copyValue(iter.Value(), depth+1))
+		}
+		return result
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(copyValue(v.Index(i), depth+1))
+		}
+		return result
+	case reflect.Array:
+		result := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(copyValue(v.Index(i), depth+1))
+		}
+		return result
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.New(v.Type().Elem())
+		result.Elem().Set(copyValue(v.Elem(), depth+1))
+		return result
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.New(v.Type()).Elem()
+		result.Set(copyValue(v.Elem(), depth+1))
+		return result
+	case reflect.Struct:
+		result := reflect.New(v.Type()).Elem()
+		result.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := result.Field(i); field.CanSet() {
+				field.Set(copyValue(v.Field(i), depth+1))
+			}
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// diffValues lists the keys added, removed or changed between two snapshots
+func diffValues(before, after map[string]any) []SessionChange {
+	var changes []SessionChange
+	for key, value := range after {
+		old, existed := before[key]
+		switch {
+		case !existed:
+			changes = append(changes, SessionChange{Key: key, Type: SessionChangeAdded, After: value})
+		case !reflect.DeepEqual(old, value):
+			changes = append(changes, SessionChange{Key: key, Type: SessionChangeChanged, Before: old, After: value})
+		}
+	}
+	for key, value := range before {
+		if _, exists := after[key]; !exists {
+			changes = append(changes, SessionChange{Key: key, Type: SessionChangeRemoved, Before: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// requestInfoFromGin returns the request info stored on a Gin context
+func requestInfoFromGin(c *gin.Context) *RequestInfo {
+	if val, exists := c.Get(string(DebugBarContextKey)); exists {
+		if reqInfo, ok := val.(*RequestInfo); ok {
+			return reqInfo
+		}
+	}
+	return nil
+}
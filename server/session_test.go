@@ -0,0 +1,188 @@
+package godebugbar
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCookieCollector(t *testing.T) {
+	config := testConfig()
+	redaction := DefaultRedactionConfig()
+	config.Redaction = &redaction
+	config.Collectors = []Collector{NewCookieCollector()}
+	d := New(config)
+	engine := testEngine(t, d)
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	engine.GET("/login", func(c *gin.Context) {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     "session_id",
+			Value:    "abc123",
+			Path:     "/",
+			Expires:  expires,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.SetCookie(c.Writer, &http.Cookie{Name: "theme", Value: "light", MaxAge: 60})
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/login", nil)
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: "old"})
+	_, captured := serve(t, d, engine, req)
+	if captured == nil {
+		t.Fatal("request wasn't captured")
+	}
+
+	wantRequest := []CookieInfo{
+		{Name: "theme", Value: "dark"},
+		{Name: "session_id", Value: DefaultRedactionReplacement},
+	}
+	if !reflect.DeepEqual(captured.RequestCookies, wantRequest) {
+		t.Errorf("RequestCookies = %+v, want %+v", captured.RequestCookies, wantRequest)
+	}
+
+	wantResponse := []CookieInfo{
+		{
+			Name:     "session_id",
+			Value:    DefaultRedactionReplacement,
+			Path:     "/",
+			Expires:  &expires,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: "Lax",
+		},
+		{Name: "theme", Value: "light", MaxAge: 60},
+	}
+	if !reflect.DeepEqual(captured.ResponseCookies, wantResponse) {
+		t.Errorf("ResponseCookies = %+v, want %+v", captured.ResponseCookies, wantResponse)
+	}
+}
+
+func TestSessionCollector(t *testing.T) {
+	session := map[string]any{
+		"user_id":  1,
+		"cart":     []string{"apple"},
+		"password": "old",
+		"flash":    "welcome",
+	}
+	collector := NewSessionCollector(SessionAdapterFunc(func(c *gin.Context) (map[string]any, error) {
+		return session, nil
+	}))
+
+	config := testConfig()
+	redaction := DefaultRedactionConfig()
+	config.Redaction = &redaction
+	config.Collectors = []Collector{collector}
+	d := New(config)
+	engine := testEngine(t, d)
+	engine.Use(collector.Middleware())
+	engine.POST("/cart", func(c *gin.Context) {
+		// Changed in place, which only shows up with a deep copy
+		session["cart"].([]string)[0] = "pear"
+		session["password"] = "new"
+		session["theme"] = "dark"
+		delete(session, "flash")
+	})
+
+	_, captured := serve(t, d, engine, httptest.NewRequest(http.MethodPost, "/cart", nil))
+	if captured == nil || captured.Session == nil {
+		t.Fatalf("captured %+v, want a session", captured)
+	}
+
+	mask := DefaultRedactionReplacement
+	want := []SessionChange{
+		{Key: "cart", Type: SessionChangeChanged, Before: []any{"apple"}, After: []any{"pear"}},
+		{Key: "flash", Type: SessionChangeRemoved, Before: "welcome"},
+		{Key: "password", Type: SessionChangeChanged, Before: mask, After: mask},
+		{Key: "theme", Type: SessionChangeAdded, After: "dark"},
+	}
+	if got := captured.Session.Changes; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes = %#v, want %#v", got, want)
+	}
+	if captured.Session.Before["password"] != mask || captured.Session.After["password"] != mask {
+		t.Errorf("snapshots = %v, %v, want the password redacted", captured.Session.Before, captured.Session.After)
+	}
+}
+
+func TestSessionCollectorError(t *testing.T) {
+	collector := NewSessionCollector(SessionAdapterFunc(func(c *gin.Context) (map[string]any, error) {
+		return nil, errors.New("session store down")
+	}))
+	req := &RequestInfo{}
+	collector.Collect(nil, req)
+	if req.Session == nil || req.Session.Error != "session store down" || req.Session.Changes != nil {
+		t.Errorf("Session = %+v, want the adapter error", req.Session)
+	}
+}
+
+func TestCopyValues(t *testing.T) {
+	type profile struct {
+		Name  string
+		Tags  []string
+		inner []string
+	}
+	type node struct {
+		Next *node
+	}
+
+	original := map[string]any{
+		"profile": &profile{Name: "ada", Tags: []string{"a"}, inner: []string{"x"}},
+		"nested":  map[string][]int{"ids": {1, 2}},
+		"array":   [2][]int{{1}, {2}},
+		"nil":     nil,
+	}
+	copied := copyValues(original)
+	if !reflect.DeepEqual(copied, original) {
+		t.Fatalf("copyValues() = %v, want an equal copy", copied)
+	}
+
+	original["profile"].(*profile).Tags[0] = "changed"
+	original["nested"].(map[string][]int)["ids"][0] = 9
+	original["array"].([2][]int)[0][0] = 9
+	if copied["profile"].(*profile).Tags[0] != "a" || copied["nested"].(map[string][]int)["ids"][0] != 1 || copied["array"].([2][]int)[0][0] != 1 {
+		t.Errorf("copy shares memory with the original: %v", copied)
+	}
+
+	// Unexported fields are copied as-is
+	original["profile"].(*profile).inner[0] = "shared"
+	if copied["profile"].(*profile).inner[0] != "shared" {
+		t.Error("unexported field was deep copied")
+	}
+
+	// A cycle ends in a shallow copy instead of recursing forever
+	cycle := &node{}
+	cycle.Next = cycle
+	if copyValues(map[string]any{"cycle": cycle})["cycle"] == nil {
+		t.Error("cyclic value was dropped")
+	}
+
+	if copyValues(nil) != nil {
+		t.Error("copyValues(nil) != nil")
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	before := map[string]any{"a": 1, "b": []int{1}, "c": "same"}
+	after := map[string]any{"b": []int{2}, "c": "same", "d": true}
+
+	want := []SessionChange{
+		{Key: "a", Type: SessionChangeRemoved, Before: 1},
+		{Key: "b", Type: SessionChangeChanged, Before: []int{1}, After: []int{2}},
+		{Key: "d", Type: SessionChangeAdded, After: true},
+	}
+	if got := diffValues(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffValues() = %+v, want %+v", got, want)
+	}
+	if got := diffValues(nil, nil); got != nil {
+		t.Errorf("diffValues(nil, nil) = %+v, want no changes", got)
+	}
+}
@@ -0,0 +1,286 @@
+package godebugbar
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// ErrRequestNotFound is returned by stores when no request has the given ID
+var ErrRequestNotFound = errors.New("request not found")
+
+// Store persists captured requests. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Add stores a completed request, evicting old ones as needed
+	Add(req *RequestInfo) error
+
+	// Get returns the request with the given ID or ErrRequestNotFound
+	Get(id string) (*RequestInfo, error)
+
+	// Update replaces a stored request with the same ID, keeping its place
+	// in the history, or returns ErrRequestNotFound
+	Update(req *RequestInfo) error
+
+	// List returns the stored requests matching filter, oldest first
+	List(filter StoreFilter) ([]*RequestInfo, error)
+
+	// Delete removes the request with the given ID
+	Delete(id string) error
+
+	// Clear removes all stored requests
+	Clear() error
+}
+
+// StoreFilter selects requests returned by Store.List
+type StoreFilter struct {
+	// Match, when set, keeps only requests for which it returns true
+	Match func(*RequestInfo) bool
+
+	// Limit, when greater than 0, keeps only the most recent matches
+	Limit int
+
+	// Search, when set, is the search Match comes from. Stores may use it
+	// to filter in their database, but must still apply Match.
+	Search *SearchQuery
+}
+
+// apply filters requests, which are ordered oldest first
+func (f StoreFilter) apply(requests []*RequestInfo) []*RequestInfo {
+	result := make([]*RequestInfo, 0, len(requests))
+	for _, req := range requests {
+		if f.Match == nil || f.Match(req) {
+			result = append(result, req)
+		}
+	}
+	if f.Limit > 0 && len(result) > f.Limit {
+		result = result[len(result)-f.Limit:]
+	}
+	return result
+}
+
+// RequestStore stores request history in memory with thread-safe access.
+// Requests live in a fixed number of slots linked in the order they were
+// added and indexed by ID, so adding, evicting, deleting and looking up a
+// request take constant time.
+type RequestStore struct {
+	mu      sync.RWMutex
+	slots   []storeSlot
+	index   map[string]int
+	free    []int // unused slots, reused before evicting
+	head    int   // oldest slot, -1 when empty
+	tail    int   // newest slot, -1 when empty
+	maxSize int
+}
+
+// storeSlot holds a request and links to the slots added before and after
+// it, -1 at either end
+type storeSlot struct {
+	req        *RequestInfo
+	prev, next int
+}
+
+// NewRequestStore creates a new request store
+func NewRequestStore(maxSize int) *RequestStore {
+	s := &RequestStore{
+		slots:   make([]storeSlot, max(maxSize, 0)),
+		index:   make(map[string]int, max(maxSize, 0)),
+		maxSize: maxSize,
+	}
+	s.reset()
+	return s
+}
+
+// Add adds a request to the store, evicting the oldest when it is full
+func (s *RequestStore) Add(req *RequestInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxSize <= 0 {
+		// A store without capacity keeps nothing
+		return nil
+	}
+
+	if old, exists := s.index[req.ID]; exists {
+		// Replace a request stored again under the same ID
+		s.unlink(old)
+	}
+	if len(s.free) == 0 {
+		s.unlink(s.head)
+	}
+
+	slot := s.free[len(s.free)-1]
+	s.free = s.free[:len(s.free)-1]
+	s.slots[slot] = storeSlot{req: req, prev: s.tail, next: -1}
+	if s.tail >= 0 {
+		s.slots[s.tail].next = slot
+	} else {
+		s.head = slot
+	}
+	s.tail = slot
+	s.index[req.ID] = slot
+	return nil
+}
+
+// unlink removes the request in a slot and frees the slot
+func (s *RequestStore) unlink(slot int) {
+	entry := s.slots[slot]
+	if entry.prev >= 0 {
+		s.slots[entry.prev].next = entry.next
+	} else {
+		s.head = entry.next
+	}
+	if entry.next >= 0 {
+		s.slots[entry.next].prev = entry.prev
+	} else {
+		s.tail = entry.prev
+	}
+	delete(s.index, entry.req.ID)
+	s.slots[slot] = storeSlot{}
+	s.free = append(s.free, slot)
+}
+
+// reset empties the store, freeing every slot
+func (s *RequestStore) reset() {
+	clear(s.slots)
+	clear(s.index)
+	s.free = s.free[:0]
+	for slot := len(s.slots) - 1; slot >= 0; slot-- {
+		s.free = append(s.free, slot)
+	}
+	s.head, s.tail = -1, -1
+}
+
+// Get returns a request by ID
+func (s *RequestStore) Get(id string) (*RequestInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if slot, ok := s.index[id]; ok {
+		return s.slots[slot].req, nil
+	}
+	return nil, ErrRequestNotFound
+}
+
+// Update implements Store
+func (s *RequestStore) Update(req *RequestInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot, ok := s.index[req.ID]
+	if !ok {
+		return ErrRequestNotFound
+	}
+	s.slots[slot].req = req
+	return nil
+}
+
+// List returns the stored requests matching filter
+func (s *RequestStore) List(filter StoreFilter) ([]*RequestInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Walk back from the newest so a limit only visits what it needs
+	capacity := len(s.index)
+	if filter.Limit > 0 {
+		capacity = min(capacity, filter.Limit)
+	}
+	result := make([]*RequestInfo, 0, capacity)
+	s.backward(func(req *RequestInfo) bool {
+		if filter.Match == nil || filter.Match(req) {
+			result = append(result, req)
+		}
+		return filter.Limit <= 0 || len(result) < filter.Limit
+	})
+	slices.Reverse(result)
+	return result, nil
+}
+
+// All iterates over the stored requests from oldest to newest. The store
+// is read-locked during iteration, so yield must not modify it.
+func (s *RequestStore) All() iter.Seq[*RequestInfo] {
+	return func(yield func(*RequestInfo) bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		s.forward(yield)
+	}
+}
+
+// Backward iterates over the stored requests from newest to oldest. The
+// store is read-locked during iteration, so yield must not modify it.
+func (s *RequestStore) Backward() iter.Seq[*RequestInfo] {
+	return func(yield func(*RequestInfo) bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		s.backward(yield)
+	}
+}
+
+// forward calls yield for each request, oldest first
+func (s *RequestStore) forward(yield func(*RequestInfo) bool) {
+	for slot := s.head; slot >= 0; slot = s.slots[slot].next {
+		if !yield(s.slots[slot].req) {
+			return
+		}
+	}
+}
+
+// backward calls yield for each request, newest first
+func (s *RequestStore) backward(yield func(*RequestInfo) bool) {
+	for slot := s.tail; slot >= 0; slot = s.slots[slot].prev {
+		if !yield(s.slots[slot].req) {
+			return
+		}
+	}
+}
+
+// Len returns the number of stored requests
+func (s *RequestStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.index)
+}
+
+// GetAll returns all stored requests
+func (s *RequestStore) GetAll() []*RequestInfo {
+	requests, _ := s.List(StoreFilter{})
+	return requests
+}
+
+// GetRecent returns the most recent n requests
+func (s *RequestStore) GetRecent(n int) []*RequestInfo {
+	if n <= 0 {
+		return make([]*RequestInfo, 0)
+	}
+	requests, _ := s.List(StoreFilter{Limit: n})
+	return requests
+}
+
+// Filter returns the stored requests for which match returns true
+func (s *RequestStore) Filter(match func(*RequestInfo) bool) []*RequestInfo {
+	requests, _ := s.List(StoreFilter{Match: match})
+	return requests
+}
+
+// Delete removes a request by ID, freeing its slot for the next request
+func (s *RequestStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot, ok := s.index[id]
+	if !ok {
+		return ErrRequestNotFound
+	}
+	s.unlink(slot)
+	return nil
+}
+
+// Clear removes all stored requests
+func (s *RequestStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+	return nil
+}
@@ -0,0 +1,417 @@
+package godebugbar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// gormBatchSize is the number of rows List decodes at a time when it has
+// to filter in Go
+const gormBatchSize = 200
+
+// ErrStoreClosed is returned when writing to a closed store
+var ErrStoreClosed = errors.New("debug bar store is closed")
+
+// storedRequest is the database row for a captured request. The columns
+// next to the JSON data are what searches can filter on in the database.
+type storedRequest struct {
+	Seq        uint64        `gorm:"primaryKey;autoIncrement"`
+	ID         string        `gorm:"size:36;uniqueIndex"`
+	StartTime  time.Time     `gorm:"index"`
+	Method     string        `gorm:"size:16;index"`
+	Path       string        `gorm:"index"`
+	Route      string        `gorm:"index"`
+	StatusCode int           `gorm:"index"`
+	Duration   time.Duration `gorm:"index"`
+	Data       []byte
+}
+
+// TableName implements gorm's Tabler
+func (storedRequest) TableName() string {
+	return "debugbar_requests"
+}
+
+// gormWrite is a change waiting to be written to the database
+type gormWrite struct {
+	op  string // add, update, delete or clear
+	req *RequestInfo
+	id  string
+}
+
+// GormStore persists requests in a database through GORM, so history
+// survives restarts. With the SQLite driver it keeps history in a local
+// file. Requests are stored as JSON, with the fields searches filter on
+// most in their own columns.
+//
+// Writes are queued and run in order on a background goroutine, keeping
+// the database off the request path, and never wait for the database. A
+// read waits only for the writes queued before it. The store doesn't limit
+// its size, the debug bar's retention evicts old requests from it.
+type GormStore struct {
+	db *gorm.DB
+
+	mu      sync.Mutex
+	ready   *sync.Cond // signalled when writes are queued or the store closes
+	written *sync.Cond // signalled when a write finishes
+	queued  []gormWrite
+	ids     map[string]bool
+	err     error
+	closed  bool
+
+	// queuedSeq counts the writes queued so far and writtenSeq those that
+	// have finished, so a read waits until writtenSeq reaches the
+	// queuedSeq it saw
+	queuedSeq  uint64
+	writtenSeq uint64
+}
+
+// NewGormStore creates a store in db, creating the debugbar_requests table
+// if needed. Call Close on shutdown to finish writing queued requests.
+func NewGormStore(db *gorm.DB) (*GormStore, error) {
+	if err := db.AutoMigrate(&storedRequest{}); err != nil {
+		return nil, fmt.Errorf("creating debug bar table: %w", err)
+	}
+
+	var ids []string
+	if err := db.Model(&storedRequest{}).Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("reading debug bar table: %w", err)
+	}
+
+	s := &GormStore{
+		db:  db,
+		ids: make(map[string]bool, len(ids)),
+	}
+	s.ready = sync.NewCond(&s.mu)
+	s.written = sync.NewCond(&s.mu)
+	for _, id := range ids {
+		s.ids[id] = true
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Add implements Store. The request is written in the background; Add
+// returns the error of an earlier write that failed, if any.
+func (s *GormStore) Add(req *RequestInfo) error {
+	s.mu.Lock()
+	s.ids[req.ID] = true
+	s.mu.Unlock()
+	return s.queue(gormWrite{op: "add", req: req})
+}
+
+// Get implements Store
+func (s *GormStore) Get(id string) (*RequestInfo, error) {
+	s.wait()
+
+	var row storedRequest
+	err := s.db.Where("id = ?", id).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.decode()
+}
+
+// Update implements Store
+func (s *GormStore) Update(req *RequestInfo) error {
+	s.mu.Lock()
+	exists := s.ids[req.ID]
+	s.mu.Unlock()
+	if !exists {
+		return ErrRequestNotFound
+	}
+	return s.queue(gormWrite{op: "update", req: req})
+}
+
+// List implements Store. Conditions of filter.Search that map to columns
+// are evaluated by the database, and rows are decoded newest first in
+// batches until the limit is reached.
+func (s *GormStore) List(filter StoreFilter) ([]*RequestInfo, error) {
+	s.wait()
+
+	query, exact := searchWhere(s.db.Model(&storedRequest{}), filter.Search)
+	if filter.Match == nil || exact {
+		var rows []storedRequest
+		query = query.Order("seq DESC")
+		if filter.Limit > 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if err := query.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		return decodeRows(rows)
+	}
+
+	var result []*RequestInfo
+	var before uint64
+	for {
+		batch := query.Session(&gorm.Session{}).Order("seq DESC").Limit(gormBatchSize)
+		if before > 0 {
+			batch = batch.Where("seq < ?", before)
+		}
+		var rows []storedRequest
+		if err := batch.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			req, err := row.decode()
+			if err != nil {
+				return nil, err
+			}
+			if filter.Match(req) {
+				result = append(result, req)
+			}
+			if filter.Limit > 0 && len(result) == filter.Limit {
+				slices.Reverse(result)
+				return result, nil
+			}
+		}
+		if len(rows) < gormBatchSize {
+			break
+		}
+		before = rows[len(rows)-1].Seq
+	}
+	slices.Reverse(result)
+	if result == nil {
+		result = make([]*RequestInfo, 0)
+	}
+	return result, nil
+}
+
+// Delete implements Store
+func (s *GormStore) Delete(id string) error {
+	s.mu.Lock()
+	exists := s.ids[id]
+	delete(s.ids, id)
+	s.mu.Unlock()
+	if !exists {
+		return ErrRequestNotFound
+	}
+	return s.queue(gormWrite{op: "delete", id: id})
+}
+
+// Clear implements Store
+func (s *GormStore) Clear() error {
+	s.mu.Lock()
+	clear(s.ids)
+	s.mu.Unlock()
+	return s.queue(gormWrite{op: "clear"})
+}
+
+// Close writes the queued requests and stops the background writer. The
+// store can still be read afterwards.
+func (s *GormStore) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.ready.Signal()
+	for s.writtenSeq < s.queuedSeq {
+		s.written.Wait()
+	}
+	err := s.err
+	s.err = nil
+	s.mu.Unlock()
+	return err
+}
+
+// queue hands a write to the background writer and returns the error of
+// an earlier write that failed. It never waits for the database, so the
+// debug bar can call it while holding its own locks.
+func (s *GormStore) queue(write gormWrite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrStoreClosed
+	}
+	s.queued = append(s.queued, write)
+	s.queuedSeq++
+	s.ready.Signal()
+
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// wait blocks until the writes queued before the call have been written.
+// Writes queued while it waits don't hold it up.
+func (s *GormStore) wait() {
+	s.mu.Lock()
+	target := s.queuedSeq
+	for s.writtenSeq < target {
+		s.written.Wait()
+	}
+	s.mu.Unlock()
+}
+
+// run writes queued changes in order until the store is closed and the
+// queue is empty
+func (s *GormStore) run() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		for len(s.queued) == 0 && !s.closed {
+			s.ready.Wait()
+		}
+		if len(s.queued) == 0 {
+			return
+		}
+		batch := s.queued
+		s.queued = nil
+
+		s.mu.Unlock()
+		for _, write := range batch {
+			err := s.write(write)
+
+			s.mu.Lock()
+			if err != nil && s.err == nil {
+				s.err = err
+			}
+			s.writtenSeq++
+			s.written.Broadcast()
+			s.mu.Unlock()
+		}
+		s.mu.Lock()
+	}
+}
+
+// write applies a single change to the database
+func (s *GormStore) write(write gormWrite) error {
+	switch write.op {
+	case "add":
+		row, err := newStoredRequest(write.req)
+		if err != nil {
+			return err
+		}
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			// A request stored again under the same ID replaces the old one
+			if err := tx.Where("id = ?", row.ID).Delete(&storedRequest{}).Error; err != nil {
+				return err
+			}
+			return tx.Create(row).Error
+		})
+	case "update":
+		row, err := newStoredRequest(write.req)
+		if err != nil {
+			return err
+		}
+		return s.db.Model(&storedRequest{}).Where("id = ?", row.ID).
+			Select("method", "path", "route", "status_code", "duration", "data").
+			Updates(row).Error
+	case "delete":
+		return s.db.Where("id = ?", write.id).Delete(&storedRequest{}).Error
+	case "clear":
+		return s.db.Where("1 = 1").Delete(&storedRequest{}).Error
+	}
+	return fmt.Errorf("unknown store write %q", write.op)
+}
+
+// newStoredRequest encodes a request as a database row
+func newStoredRequest(req *RequestInfo) (*storedRequest, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return &storedRequest{
+		ID:         req.ID,
+		StartTime:  req.StartTime,
+		Method:     strings.ToUpper(req.Method),
+		Path:       req.Path,
+		Route:      req.Route,
+		StatusCode: req.StatusCode,
+		Duration:   req.Duration,
+		Data:       data,
+	}, nil
+}
+
+// searchWhere narrows query to the rows that can match search. It reports
+// whether the conditions match exactly the requests search does, so the
+// rows don't need filtering in Go.
+func searchWhere(query *gorm.DB, search *SearchQuery) (*gorm.DB, bool) {
+	if search == nil {
+		return query, false
+	}
+
+	exact := true
+	for _, term := range search.terms {
+		if len(term.conditions) == 0 || !term.exact {
+			exact = false
+		}
+		if len(term.conditions) == 0 || (term.negate && !term.exact) {
+			continue
+		}
+
+		clauses := make([]string, 0, len(term.conditions))
+		args := make([]any, 0, len(term.conditions))
+		for _, condition := range term.conditions {
+			if condition.op == "like" {
+				clauses = append(clauses, condition.column+" LIKE ? ESCAPE '!'")
+				args = append(args, sqlLikePattern(condition.value.(string)))
+				continue
+			}
+			clauses = append(clauses, condition.column+" "+condition.op+" ?")
+			args = append(args, condition.value)
+		}
+
+		clause := strings.Join(clauses, " AND ")
+		if term.negate {
+			clause = "NOT (" + clause + ")"
+		}
+		query = query.Where(clause, args...)
+	}
+	return query, exact
+}
+
+// sqlLikePattern converts a search glob to an SQL LIKE pattern escaped with !
+func sqlLikePattern(glob string) string {
+	var pattern strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			pattern.WriteByte('%')
+		case '?':
+			pattern.WriteByte('_')
+		case '%', '_', '!':
+			pattern.WriteByte('!')
+			pattern.WriteRune(r)
+		default:
+			pattern.WriteRune(r)
+		}
+	}
+	return pattern.String()
+}
+
+// decodeRows unmarshals rows read newest first into requests oldest first
+func decodeRows(rows []storedRequest) ([]*RequestInfo, error) {
+	requests := make([]*RequestInfo, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		req, err := rows[i].decode()
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// decode unmarshals the stored request
+func (r *storedRequest) decode() (*RequestInfo, error) {
+	req := &RequestInfo{}
+	if err := json.Unmarshal(r.Data, req); err != nil {
+		return nil, fmt.Errorf("decoding stored request %s: %w", r.ID, err)
+	}
+	return req, nil
+}
@@ -0,0 +1,226 @@
+package godebugbar
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openGormStore opens a GormStore on the SQLite file at path
+func openGormStore(t *testing.T, path string) *GormStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewGormStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return store
+}
+
+// requestIDs returns the IDs of requests in order
+func requestIDs(requests []*RequestInfo) []string {
+	ids := make([]string, len(requests))
+	for i, req := range requests {
+		ids[i] = req.ID
+	}
+	return ids
+}
+
+func TestGormStore(t *testing.T) {
+	store := openGormStore(t, filepath.Join(t.TempDir(), "debugbar.db"))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, method := range []string{"GET", "POST", "GET"} {
+		req := &RequestInfo{ID: fmt.Sprint("r", i), Method: method, Path: "/items", StatusCode: 200, StartTime: start}
+		if err := store.Add(req); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	req, err := store.Get("r1")
+	if err != nil || req.Method != "POST" || !req.StartTime.Equal(start) {
+		t.Fatalf("Get() = %+v, %v", req, err)
+	}
+	if _, err := store.Get("missing"); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrRequestNotFound", err)
+	}
+
+	all, err := store.List(StoreFilter{})
+	if ids := requestIDs(all); err != nil || !slices.Equal(ids, []string{"r0", "r1", "r2"}) {
+		t.Errorf("List() = %v, %v, want oldest first", ids, err)
+	}
+	recent, _ := store.List(StoreFilter{Limit: 2})
+	if ids := requestIDs(recent); !slices.Equal(ids, []string{"r1", "r2"}) {
+		t.Errorf("List(Limit: 2) = %v, want the most recent", ids)
+	}
+	gets, _ := store.List(StoreFilter{Match: func(req *RequestInfo) bool { return req.Method == "GET" }, Limit: 1})
+	if ids := requestIDs(gets); !slices.Equal(ids, []string{"r2"}) {
+		t.Errorf("List(Match, Limit: 1) = %v, want the newest GET", ids)
+	}
+
+	if err := store.Update(&RequestInfo{ID: "r0", Method: "GET", Path: "/items", StatusCode: 500, Notes: []RequestNote{{Text: "slow"}}}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if req, _ := store.Get("r0"); req.StatusCode != 500 || len(req.Notes) != 1 {
+		t.Errorf("updated request = %+v", req)
+	}
+	if err := store.Update(&RequestInfo{ID: "missing"}); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrRequestNotFound", err)
+	}
+
+	// Adding an existing ID replaces the request and makes it the newest
+	store.Add(&RequestInfo{ID: "r0", Method: "DELETE"})
+	all, _ = store.List(StoreFilter{})
+	if ids := requestIDs(all); !slices.Equal(ids, []string{"r1", "r2", "r0"}) || all[2].Method != "DELETE" {
+		t.Errorf("List() after re-adding = %v", ids)
+	}
+
+	if err := store.Delete("r1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete("r1"); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("second Delete() error = %v, want ErrRequestNotFound", err)
+	}
+	if _, err := store.Get("r1"); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Get(deleted) error = %v, want ErrRequestNotFound", err)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if all, _ := store.List(StoreFilter{}); len(all) != 0 {
+		t.Errorf("List() after Clear() = %v", requestIDs(all))
+	}
+	if err := store.Update(&RequestInfo{ID: "r2"}); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Update() after Clear() error = %v, want ErrRequestNotFound", err)
+	}
+}
+
+func TestGormStoreQueue(t *testing.T) {
+	store := openGormStore(t, filepath.Join(t.TempDir(), "debugbar.db"))
+
+	// Far more writes than the writer keeps up with are queued without
+	// waiting, and a read sees every write queued before it
+	const n = 3000
+	for i := range n {
+		if err := store.Add(&RequestInfo{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	all, err := store.List(StoreFilter{})
+	if err != nil || len(all) != n || all[n-1].ID != fmt.Sprint(n-1) {
+		t.Fatalf("List() returned %d requests, %v, want %d", len(all), err, n)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := store.Add(&RequestInfo{ID: "late"}); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Add() after Close() error = %v, want ErrStoreClosed", err)
+	}
+	if _, err := store.Get("0"); err != nil {
+		t.Errorf("Get() after Close() error = %v, want reads to keep working", err)
+	}
+}
+
+func TestGormStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debugbar.db")
+	store := openGormStore(t, path)
+	store.Add(&RequestInfo{ID: "a", Method: "GET", Path: "/kept"})
+	store.Add(&RequestInfo{ID: "b", Method: "POST", Path: "/kept"})
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openGormStore(t, path)
+	all, err := reopened.List(StoreFilter{})
+	if ids := requestIDs(all); err != nil || !slices.Equal(ids, []string{"a", "b"}) {
+		t.Fatalf("List() after reopening = %v, %v", ids, err)
+	}
+	// Requests from the earlier run can be changed without adding them again
+	if err := reopened.Update(&RequestInfo{ID: "a", Method: "GET", Path: "/kept", StatusCode: 204}); err != nil {
+		t.Errorf("Update() of a persisted request error = %v", err)
+	}
+	if err := reopened.Delete("b"); err != nil {
+		t.Errorf("Delete() of a persisted request error = %v", err)
+	}
+	all, _ = reopened.List(StoreFilter{})
+	if len(all) != 1 || all[0].StatusCode != 204 {
+		t.Errorf("List() = %+v, want the updated request only", all)
+	}
+}
+
+func TestGormStoreSearch(t *testing.T) {
+	gormStore := openGormStore(t, filepath.Join(t.TempDir(), "debugbar.db"))
+	memory := NewRequestStore(100)
+
+	requests := []*RequestInfo{
+		{ID: "1", Method: "GET", Path: "/orders", Route: "/orders", StatusCode: 200, Duration: 50 * time.Millisecond},
+		{ID: "2", Method: "POST", Path: "/orders/5", Route: "/orders/:id", StatusCode: 201, Duration: 300 * time.Millisecond},
+		{ID: "3", Method: "get", Path: "/Orders/6", Route: "/orders/:id", StatusCode: 404, Duration: 10 * time.Millisecond},
+		{ID: "4", Method: "DELETE", Path: "/order_items/1", Route: "/order_items/:id", StatusCode: 500, Duration: time.Second,
+			Errors: []ErrorInfo{{Message: "boom"}}},
+		{ID: "5", Method: "GET", Path: "/orderXitems/1", StatusCode: 502},
+	}
+	for _, req := range requests {
+		gormStore.Add(req)
+		memory.Add(req)
+	}
+
+	tests := []struct {
+		query string
+		exact bool
+	}{
+		{"method:get", true},
+		{"-method:GET", true},
+		{"path:/orders", true},
+		{"path:/orders*", false},
+		{"path:/order_items/*", false},
+		{"route:/orders/:id", true},
+		{"status:5xx", true},
+		{"status:>=201 -status:500", true},
+		{"duration:>200ms", true},
+		{"method:GET status:<300", true},
+		{"has:error", false},
+		{"-path:/orders*", false},
+		{"orders", false},
+		{"method:GET has:error", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			search, err := ParseSearch(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, exact := searchWhere(gormStore.db, search); exact != tt.exact {
+				t.Errorf("searchWhere() exact = %v, want %v", exact, tt.exact)
+			}
+
+			filter := StoreFilter{Match: search.Match, Search: search}
+			want, _ := memory.List(filter)
+			got, err := gormStore.List(filter)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if !slices.Equal(requestIDs(got), requestIDs(want)) {
+				t.Errorf("database search found %v, want %v", requestIDs(got), requestIDs(want))
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package godebugbar
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// storeIDs returns the IDs of a store's requests, oldest first
+func storeIDs(s *RequestStore) []string {
+	var ids []string
+	for req := range s.All() {
+		ids = append(ids, req.ID)
+	}
+	return ids
+}
+
+func TestRequestStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		add     []string
+		delete  []string
+		addMore []string
+		want    []string
+	}{
+		{
+			name: "keeps insertion order",
+			size: 3,
+			add:  []string{"a", "b", "c"},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "evicts the oldest when full",
+			size: 3,
+			add:  []string{"a", "b", "c", "d", "e"},
+			want: []string{"c", "d", "e"},
+		},
+		{
+			name:    "reuses deleted slots before evicting",
+			size:    3,
+			add:     []string{"a", "b", "c"},
+			delete:  []string{"b"},
+			addMore: []string{"d"},
+			want:    []string{"a", "c", "d"},
+		},
+		{
+			name:    "evicts after the freed slots are used",
+			size:    3,
+			add:     []string{"a", "b", "c"},
+			delete:  []string{"b"},
+			addMore: []string{"d", "e"},
+			want:    []string{"c", "d", "e"},
+		},
+		{
+			name:    "replaces a request added again",
+			size:    3,
+			add:     []string{"a", "b", "c"},
+			addMore: []string{"a"},
+			want:    []string{"b", "c", "a"},
+		},
+		{
+			name: "keeps nothing without capacity",
+			size: 0,
+			add:  []string{"a", "b"},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRequestStore(tt.size)
+			for _, id := range tt.add {
+				s.Add(&RequestInfo{ID: id})
+			}
+			for _, id := range tt.delete {
+				if err := s.Delete(id); err != nil {
+					t.Fatalf("Delete(%q) = %v", id, err)
+				}
+			}
+			for _, id := range tt.addMore {
+				s.Add(&RequestInfo{ID: id})
+			}
+
+			if got := storeIDs(s); !slices.Equal(got, tt.want) {
+				t.Errorf("requests = %v, want %v", got, tt.want)
+			}
+			if s.Len() != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", s.Len(), len(tt.want))
+			}
+
+			var backward []string
+			for req := range s.Backward() {
+				backward = append(backward, req.ID)
+			}
+			slices.Reverse(backward)
+			if !slices.Equal(backward, tt.want) {
+				t.Errorf("Backward() = %v reversed, want %v", backward, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestStoreLookups(t *testing.T) {
+	s := NewRequestStore(10)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		s.Add(&RequestInfo{ID: id, Method: "GET"})
+	}
+
+	if _, err := s.Get("missing"); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrRequestNotFound", err)
+	}
+	if err := s.Delete("missing"); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Delete(missing) error = %v, want ErrRequestNotFound", err)
+	}
+	if err := s.Update(&RequestInfo{ID: "missing"}); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrRequestNotFound", err)
+	}
+
+	if err := s.Update(&RequestInfo{ID: "b", Method: "POST"}); err != nil {
+		t.Fatalf("Update(b) = %v", err)
+	}
+	req, err := s.Get("b")
+	if err != nil || req.Method != "POST" {
+		t.Errorf("Get(b) = %+v, %v, want the updated request", req, err)
+	}
+	if got := storeIDs(s); !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Update moved the request: %v", got)
+	}
+
+	filters := []struct {
+		name   string
+		filter StoreFilter
+		want   []string
+	}{
+		{"all", StoreFilter{}, []string{"a", "b", "c", "d"}},
+		{"limit keeps the newest", StoreFilter{Limit: 2}, []string{"c", "d"}},
+		{"match", StoreFilter{Match: func(req *RequestInfo) bool { return req.Method == "GET" }}, []string{"a", "c", "d"}},
+		{
+			"match with limit",
+			StoreFilter{Match: func(req *RequestInfo) bool { return req.Method == "GET" }, Limit: 2},
+			[]string{"c", "d"},
+		},
+	}
+	for _, tt := range filters {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, err := s.List(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, req := range requests {
+				got = append(got, req.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("List() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	s.Clear()
+	if s.Len() != 0 || len(s.GetAll()) != 0 {
+		t.Errorf("store not empty after Clear: %v", storeIDs(s))
+	}
+}
+
+// benchmarkStoreSize is the number of requests retained in the store
+// benchmarks
+const benchmarkStoreSize = 5000
+
+// filledStore returns a full store and the IDs of its requests
+func filledStore() (*RequestStore, []string) {
+	s := NewRequestStore(benchmarkStoreSize)
+	ids := make([]string, benchmarkStoreSize)
+	for i := range ids {
+		ids[i] = "request-" + strconv.Itoa(i)
+		s.Add(&RequestInfo{ID: ids[i], Method: "GET", Path: "/orders/" + strconv.Itoa(i), StatusCode: 200 + i%4*100})
+	}
+	return s, ids
+}
+
+func BenchmarkRequestStoreAdd(b *testing.B) {
+	s, _ := filledStore()
+	var n atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Add(&RequestInfo{ID: "added-" + strconv.FormatInt(n.Add(1), 10)})
+		}
+	})
+}
+
+func BenchmarkRequestStoreGet(b *testing.B) {
+	s, ids := filledStore()
+	var n atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := s.Get(ids[n.Add(1)%benchmarkStoreSize]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRequestStoreList(b *testing.B) {
+	s, _ := filledStore()
+	search, err := ParseSearch("status:5xx path:/orders/1*")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := s.List(StoreFilter{Match: search.Match, Search: search, Limit: 50}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -1,54 +1,111 @@
 package godebugbar
 
 import (
-	"sync"
 	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 // RequestInfo holds information about an HTTP request
 type RequestInfo struct {
-	ID            string            `json:"id"`
-	Method        string            `json:"method"`
-	Path          string            `json:"path"`
-	StatusCode    int               `json:"status_code"`
-	Duration      time.Duration     `json:"duration"`
-	DurationMs    float64           `json:"duration_ms"`
-	StartTime     time.Time         `json:"start_time"`
-	EndTime       time.Time         `json:"end_time"`
-	Headers       map[string]string `json:"headers"`
-	QueryParams   map[string]string `json:"query_params"`
-	RequestBody   string            `json:"request_body,omitempty"`
-	ResponseSize  int               `json:"response_size"`
-	ClientIP      string            `json:"client_ip"`
-	Queries       []QueryInfo       `json:"queries"`
-	Errors        []ErrorInfo       `json:"errors"`
-	MemoryUsage   uint64            `json:"memory_usage"`
-	CustomData    map[string]any    `json:"custom_data,omitempty"`
+	ID                    string            `json:"id"`
+	Method                string            `json:"method"`
+	Path                  string            `json:"path"`
+	Route                 string            `json:"route,omitempty"`
+	PathParams            []PathParam       `json:"path_params,omitempty"`
+	HandlerName           string            `json:"handler_name,omitempty"`
+	Unmatched             bool              `json:"unmatched,omitempty"`
+	StatusCode            int               `json:"status_code"`
+	Duration              time.Duration     `json:"duration"`
+	DurationMs            float64           `json:"duration_ms"`
+	StartTime             time.Time         `json:"start_time"`
+	EndTime               time.Time         `json:"end_time"`
+	Headers               map[string]string `json:"headers,omitempty"`
+	QueryParams           map[string]string `json:"query_params,omitempty"`
+	RequestHeaders        Fields            `json:"request_headers"`
+	QueryValues           Fields            `json:"query_values"`
+	ResponseHeaders       Fields            `json:"response_headers,omitempty"`
+	RequestBody           string            `json:"request_body,omitempty"`
+	RequestBodySize       int64             `json:"request_body_size"`
+	RequestBodyTruncated  bool              `json:"request_body_truncated,omitempty"`
+	RequestBodyFormat     string            `json:"request_body_format,omitempty"`
+	Form                  *FormData         `json:"form,omitempty"`
+	ResponseSize          int               `json:"response_size"`
+	ResponseContentType   string            `json:"response_content_type,omitempty"`
+	ResponseBody          string            `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool              `json:"response_body_truncated,omitempty"`
+	ResponseBodyFormat    string            `json:"response_body_format,omitempty"`
+	ClientIP              string            `json:"client_ip"`
+	User                  *UserInfo         `json:"user,omitempty"`
+	ActivatedBy           string            `json:"activated_by,omitempty"`
+	Queries               []QueryInfo       `json:"queries"`
+	Errors                []ErrorInfo       `json:"errors"`
+	MemoryUsage           uint64            `json:"memory_usage"`
+	CustomData            map[string]any    `json:"custom_data,omitempty"`
+	ContextKeys           map[string]any    `json:"context_keys,omitempty"`
+	ValidationErrors      []ValidationError `json:"validation_errors,omitempty"`
+	RequestCookies        []CookieInfo      `json:"request_cookies,omitempty"`
+	ResponseCookies       []CookieInfo      `json:"response_cookies,omitempty"`
+	Session               *SessionInfo      `json:"session,omitempty"`
+	Handlers              []HandlerTiming   `json:"handlers,omitempty"`
+	AbortedBy             string            `json:"aborted_by,omitempty"`
+	Partial               bool              `json:"partial,omitempty"`
+	Pinned                bool              `json:"pinned,omitempty"`
+	Notes                 []RequestNote     `json:"notes,omitempty"`
+	Labels                []string          `json:"labels,omitempty"`
+	Imported              bool              `json:"imported,omitempty"`
+	ImportedAt            *time.Time        `json:"imported_at,omitempty"`
+
+	chain *handlerChain
+
+	// shapeCounts counts the queries of each normalized shape, for the
+	// query_in_loop lint rule
+	shapeCounts map[string]int
+}
+
+// UserInfo identifies the authenticated user that made a request
+type UserInfo struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name,omitempty"`
+	Roles      []string       `json:"roles,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// UserResolver returns the user for a request, or nil if it is anonymous
+type UserResolver func(c *gin.Context) *UserInfo
+
+// PathParam is a parameter matched from the route pattern, such as the id
+// in /users/:id
+type PathParam struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
 }
 
 // QueryInfo holds information about a database query
 type QueryInfo struct {
-	ID        string        `json:"id"`
-	RequestID string        `json:"request_id"`
-	Query     string        `json:"query"`
-	Args      []any         `json:"args,omitempty"`
-	Duration  time.Duration `json:"duration"`
-	DurationMs float64      `json:"duration_ms"`
-	RowsAffected int64      `json:"rows_affected"`
-	Error     string        `json:"error,omitempty"`
-	StartTime time.Time     `json:"start_time"`
-	Source    string        `json:"source,omitempty"`
+	ID           string        `json:"id"`
+	RequestID    string        `json:"request_id"`
+	Query        string        `json:"query"`
+	Args         []any         `json:"args,omitempty"`
+	Duration     time.Duration `json:"duration"`
+	DurationMs   float64       `json:"duration_ms"`
+	RowsAffected int64         `json:"rows_affected"`
+	Error        string        `json:"error,omitempty"`
+	StartTime    time.Time     `json:"start_time"`
+	Source       string        `json:"source,omitempty"`
+
+	shape string // normalized SQL, computed once per query
 }
 
 // ErrorInfo holds information about an error
 type ErrorInfo struct {
-	ID         string    `json:"id"`
-	RequestID  string    `json:"request_id"`
-	Message    string    `json:"message"`
-	Stack      string    `json:"stack,omitempty"`
-	Type       string    `json:"type"`
-	Timestamp  time.Time `json:"timestamp"`
-	Context    map[string]any `json:"context,omitempty"`
+	ID        string         `json:"id"`
+	RequestID string         `json:"request_id"`
+	Message   string         `json:"message"`
+	Stack     string         `json:"stack,omitempty"`
+	Type      string         `json:"type"`
+	Timestamp time.Time      `json:"timestamp"`
+	Context   map[string]any `json:"context,omitempty"`
 }
 
 // WebSocketMessage represents a message sent over WebSocket
@@ -59,116 +116,141 @@ type WebSocketMessage struct {
 
 // Message types for WebSocket communication
 const (
-	MessageTypeRequest     = "request"
-	MessageTypeQuery       = "query"
-	MessageTypeError       = "error"
-	MessageTypeRequestEnd  = "request_end"
-	MessageTypeHistory     = "history"
-	MessageTypePing        = "ping"
-	MessageTypePong        = "pong"
+	MessageTypeRequest    = "request"
+	MessageTypeQuery      = "query"
+	MessageTypeError      = "error"
+	MessageTypeRequestEnd = "request_end"
+	MessageTypeHistory    = "history"
+	MessageTypeEvicted    = "evicted"
+	MessageTypeAnnotation = "annotation"
+	MessageTypePing       = "ping"
+	MessageTypePong       = "pong"
 )
 
+// Commands sent by WebSocket clients and their replies
+const (
+	MessageTypeSearch        = "search"
+	MessageTypeSearchResults = "search_results"
+	MessageTypePin           = "pin"
+	MessageTypeAddNote       = "add_note"
+	MessageTypeRemoveNote    = "remove_note"
+	MessageTypeSetLabels     = "set_labels"
+	MessageTypeCommandError  = "command_error"
+)
+
+// DefaultAPIPath is the default prefix for the debug bar's JSON API
+const DefaultAPIPath = "/_debugbar/api"
+
 // Config holds the debug bar configuration
 type Config struct {
 	// Enabled determines if the debug bar is active
 	Enabled bool
 
+	// Activation, when set, only captures requests that carry a valid
+	// activation token
+	Activation *ActivationConfig
+
 	// WebSocketPath is the path where the WebSocket server will listen
 	WebSocketPath string
 
+	// APIPath is the prefix for the JSON API served by Handler and
+	// ListenAndServe. Defaults to "/_debugbar/api" when empty.
+	APIPath string
+
+	// ReadOnly stops capturing requests and rejects changes to the history
+	// such as deleting or annotating requests. Archives can still be
+	// imported, so a read-only debug bar can serve captures from others.
+	ReadOnly bool
+
 	// MaxRequests is the maximum number of requests to keep in history
 	MaxRequests int
 
+	// Retention evicts requests by size and age, letting errors, slow and
+	// pinned requests outlive routine ones. When nil only MaxRequests
+	// applies.
+	Retention *RetentionPolicy
+
 	// CaptureRequestBody determines if request bodies should be captured
 	CaptureRequestBody bool
 
 	// MaxBodySize is the maximum size of request body to capture
 	MaxBodySize int
 
-	// AllowedOrigins for WebSocket CORS
-	AllowedOrigins []string
-}
+	// CaptureResponseBody determines if response bodies should be captured
+	CaptureResponseBody bool
 
-// DefaultConfig returns the default configuration
-func DefaultConfig() Config {
-	return Config{
-		Enabled:            true,
-		WebSocketPath:      "/_debugbar/ws",
-		MaxRequests:        100,
-		CaptureRequestBody: true,
-		MaxBodySize:        64 * 1024, // 64KB
-		AllowedOrigins:     []string{"*"},
-	}
-}
+	// MaxResponseBodySize is the maximum size of response body to capture
+	MaxResponseBodySize int
 
-// RequestStore stores request history with thread-safe access
-type RequestStore struct {
-	mu       sync.RWMutex
-	requests []*RequestInfo
-	maxSize  int
-}
+	// BinaryPreview is the format used to preview binary bodies, either
+	// BodyFormatHex (the default) or BodyFormatBase64
+	BinaryPreview string
 
-// NewRequestStore creates a new request store
-func NewRequestStore(maxSize int) *RequestStore {
-	return &RequestStore{
-		requests: make([]*RequestInfo, 0, maxSize),
-		maxSize:  maxSize,
-	}
-}
+	// BodyDecoders render bodies of specific media types as text, keyed by
+	// media type such as "application/x-protobuf" or "image/*"
+	BodyDecoders map[string]BodyDecoder
 
-// Add adds a request to the store
-func (s *RequestStore) Add(req *RequestInfo) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// CaptureRules decide which requests are captured and whether their
+	// bodies are kept. The first matching rule applies.
+	CaptureRules []CaptureRule
 
-	if len(s.requests) >= s.maxSize {
-		// Remove oldest request
-		s.requests = s.requests[1:]
-	}
-	s.requests = append(s.requests, req)
-}
+	// AlwaysCaptureServerErrors records requests skipped by CaptureRules
+	// that end with a 5xx status
+	AlwaysCaptureServerErrors bool
 
-// Get returns a request by ID
-func (s *RequestStore) Get(id string) *RequestInfo {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	// AlwaysCaptureErrors records requests skipped by CaptureRules that end
+	// with errors added to the Gin context
+	AlwaysCaptureErrors bool
 
-	for _, req := range s.requests {
-		if req.ID == id {
-			return req
-		}
-	}
-	return nil
-}
+	// AllowedOrigins are the page origins, such as "https://admin.example.com",
+	// allowed to connect to the WebSocket and import archives. "*" allows
+	// any origin. When empty, only the debug bar's own host and loopback
+	// hosts are allowed.
+	AllowedOrigins []string
 
-// GetAll returns all stored requests
-func (s *RequestStore) GetAll() []*RequestInfo {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	// Auth authenticates clients connecting to the WebSocket endpoint.
+	// Defaults to LocalhostOnly() when nil.
+	Auth Authenticator
 
-	result := make([]*RequestInfo, len(s.requests))
-	copy(result, s.requests)
-	return result
-}
+	// MultiValueOnly drops the legacy single-valued headers and query_params
+	// maps from captured requests, leaving only the multi-valued fields
+	MultiValueOnly bool
 
-// GetRecent returns the most recent n requests
-func (s *RequestStore) GetRecent(n int) []*RequestInfo {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	// LintRules are run against every captured query; warnings are added
+	// to the request's error list
+	LintRules []LintRule
 
-	if n > len(s.requests) {
-		n = len(s.requests)
-	}
+	// Redaction configures masking of sensitive data before requests are
+	// stored or broadcast. Defaults to DefaultRedactionConfig() when nil, use
+	// an empty RedactionConfig to turn redaction off.
+	Redaction *RedactionConfig
 
-	result := make([]*RequestInfo, n)
-	copy(result, s.requests[len(s.requests)-n:])
-	return result
-}
+	// UserResolver identifies the user that made each request. It is
+	// called after the handler chain has run.
+	UserResolver UserResolver
+
+	// Collectors gather extra data about each request after the handler
+	// chain has run
+	Collectors []Collector
 
-// Clear removes all stored requests
-func (s *RequestStore) Clear() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// ValueEncoder converts query args, custom data and error context into
+	// JSON-safe values. Defaults to DefaultValueEncoder() when nil.
+	ValueEncoder *ValueEncoder
+}
 
-	s.requests = make([]*RequestInfo, 0, s.maxSize)
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		Enabled:             true,
+		WebSocketPath:       "/_debugbar/ws",
+		APIPath:             DefaultAPIPath,
+		MaxRequests:         100,
+		CaptureRequestBody:  true,
+		MaxBodySize:         64 * 1024, // 64KB
+		CaptureResponseBody: true,
+		MaxResponseBodySize: 64 * 1024, // 64KB
+		LintRules:           DefaultLintRules(),
+		Collectors:          DefaultCollectors(),
+		ValueEncoder:        DefaultValueEncoder(),
+	}
 }
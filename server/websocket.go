@@ -2,7 +2,6 @@ package godebugbar
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"sync"
 	"time"
@@ -21,8 +20,9 @@ const (
 	// Send pings to peer with this period (must be less than pongWait)
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 512
+	// Maximum message size allowed from peer, which sends pings and
+	// commands such as searches
+	maxMessageSize = 8192
 )
 
 // WebSocketClient represents a connected WebSocket client
@@ -31,15 +31,33 @@ type WebSocketClient struct {
 	conn *websocket.Conn
 	send chan []byte
 	mu   sync.Mutex
+
+	// server is the http.Server the client connected through, nil when it
+	// isn't known
+	server *http.Server
+
+	// commands handles messages other than pings
+	commands func(c *WebSocketClient, msg clientMessage)
+}
+
+// clientMessage is a message received from a WebSocket client, with the
+// payload decoded by the command handling it
+type clientMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
 }
 
 // WebSocketHub maintains the set of active clients and broadcasts messages
 type WebSocketHub struct {
 	clients    map[*WebSocketClient]bool
 	broadcast  chan []byte
-	register   chan *WebSocketClient
 	unregister chan *WebSocketClient
+	logger     Logger
 	mu         sync.RWMutex
+
+	// done is closed by Stop
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewWebSocketHub creates a new WebSocket hub
@@ -47,19 +65,18 @@ func NewWebSocketHub() *WebSocketHub {
 	return &WebSocketHub{
 		clients:    make(map[*WebSocketClient]bool),
 		broadcast:  make(chan []byte, 256),
-		register:   make(chan *WebSocketClient),
 		unregister: make(chan *WebSocketClient),
+		logger:     defaultLogger,
+		done:       make(chan struct{}),
 	}
 }
 
-// Run starts the hub's main loop
+// Run starts the hub's main loop, which returns once Stop is called
 func (h *WebSocketHub) Run() {
 	for {
 		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
+		case <-h.done:
+			return
 
 		case client := <-h.unregister:
 			h.mu.Lock()
@@ -88,11 +105,41 @@ func (h *WebSocketHub) Run() {
 	}
 }
 
+// Stop ends Run and disconnects every client. A stopped hub accepts no new
+// clients and drops broadcasts.
+func (h *WebSocketHub) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.done)
+
+		// Closing a client's send channel makes its writePump send a close
+		// message and close the connection
+		h.mu.Lock()
+		for client := range h.clients {
+			delete(h.clients, client)
+			close(client.send)
+		}
+		h.mu.Unlock()
+	})
+}
+
+// stopped reports whether Stop has been called
+func (h *WebSocketHub) stopped() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // Broadcast sends a message to all connected clients
 func (h *WebSocketHub) Broadcast(msg WebSocketMessage) {
+	if h.stopped() {
+		return
+	}
 	data, err := json.Marshal(msg)
 	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
+		h.logger.Printf("Error marshaling WebSocket message: %v", err)
 		return
 	}
 
@@ -100,7 +147,38 @@ func (h *WebSocketHub) Broadcast(msg WebSocketMessage) {
 	case h.broadcast <- data:
 	default:
 		// Channel is full, drop the message
-		log.Println("WebSocket broadcast channel full, dropping message")
+		h.logger.Printf("WebSocket broadcast channel full, dropping message")
+	}
+}
+
+// add registers a client so it receives broadcasts. Clients are added
+// directly rather than through Run, so messages can be sent to them as soon
+// as add returns. It reports false when the hub has been stopped.
+func (h *WebSocketHub) add(client *WebSocketClient) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped() {
+		return false
+	}
+	h.clients[client] = true
+	return true
+}
+
+// send queues a message for a single client without blocking. It reports
+// false when the client is gone, its send channel being closed, or its
+// buffer is full.
+func (h *WebSocketHub) send(client *WebSocketClient, data []byte) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if !h.clients[client] {
+		return false
+	}
+	select {
+	case client.send <- data:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -111,50 +189,69 @@ func (h *WebSocketHub) ClientCount() int {
 	return len(h.clients)
 }
 
-// handleWebSocket handles WebSocket connections
+// closeServer disconnects the clients that connected through server,
+// telling them the server is going away
+func (h *WebSocketHub) closeServer(server *http.Server) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for client := range h.clients {
+		if client.server != server {
+			continue
+		}
+		client.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+		client.conn.Close()
+	}
+}
+
+// handleWebSocket handles WebSocket connections on the application's router
 func (d *DebugBar) handleWebSocket(c *gin.Context) {
+	if !d.authenticate(c.Writer, c.Request) {
+		return
+	}
+	d.serveWebSocket(c.Writer, c.Request)
+}
+
+// serveWebSocket upgrades an authenticated request to a WebSocket connection
+func (d *DebugBar) serveWebSocket(w http.ResponseWriter, r *http.Request) {
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
-		CheckOrigin: func(r *http.Request) bool {
-			// Check allowed origins
-			if len(d.config.AllowedOrigins) == 0 {
-				return true
-			}
-			origin := r.Header.Get("Origin")
-			for _, allowed := range d.config.AllowedOrigins {
-				if allowed == "*" || allowed == origin {
-					return true
-				}
-			}
-			return false
-		},
+		CheckOrigin:     d.originAllowed,
 	}
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		d.logger.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
 
 	client := &WebSocketClient{
-		hub:  d.wsHub,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:      d.wsHub,
+		conn:     conn,
+		send:     make(chan []byte, 256),
+		commands: d.handleCommand,
 	}
+	client.server, _ = r.Context().Value(http.ServerContextKey).(*http.Server)
 
-	d.wsHub.register <- client
+	if !d.wsHub.add(client) {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "debug bar closed"), time.Now().Add(writeWait))
+		conn.Close()
+		return
+	}
 
 	// Send history on connect
 	go func() {
-		history := d.store.GetAll()
+		history := d.GetHistory()
 		msg := WebSocketMessage{
 			Type:    MessageTypeHistory,
 			Payload: history,
 		}
 		data, err := json.Marshal(msg)
 		if err == nil {
-			client.send <- data
+			d.wsHub.send(client, data)
 		}
 	}()
 
@@ -166,7 +263,11 @@ func (d *DebugBar) handleWebSocket(c *gin.Context) {
 // readPump pumps messages from the WebSocket connection to the hub
 func (c *WebSocketClient) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+			// Stop already removed the client
+		}
 		c.conn.Close()
 	}()
 
@@ -181,23 +282,36 @@ func (c *WebSocketClient) readPump() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				c.hub.logger.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
 
 		// Handle incoming messages (e.g., ping)
-		var msg WebSocketMessage
+		var msg clientMessage
 		if err := json.Unmarshal(message, &msg); err == nil {
-			if msg.Type == MessageTypePing {
-				response := WebSocketMessage{Type: MessageTypePong}
-				data, _ := json.Marshal(response)
-				c.send <- data
+			switch {
+			case msg.Type == MessageTypePing:
+				c.reply(WebSocketMessage{Type: MessageTypePong})
+			case c.commands != nil:
+				c.commands(c, msg)
 			}
 		}
 	}
 }
 
+// reply sends a message to this client only
+func (c *WebSocketClient) reply(msg WebSocketMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.hub.logger.Printf("Error marshaling WebSocket message: %v", err)
+		return
+	}
+	if !c.hub.send(c, data) {
+		c.hub.logger.Printf("WebSocket client gone or too slow, dropping %s reply", msg.Type)
+	}
+}
+
 // writePump pumps messages from the hub to the WebSocket connection
 func (c *WebSocketClient) writePump() {
 	ticker := time.NewTicker(pingPeriod)